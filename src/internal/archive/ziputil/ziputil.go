@@ -1,29 +1,266 @@
 package ziputil
 
 import (
+	"archive/tar"
 	"archive/zip"
 	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io"
+	"path"
+	"strings"
+
+	"internal/store/spool"
+)
+
+// maxDepth limits nesting of archives (e.g. zip in zip)
+const maxDepth = 4
+
+type format int
+
+const (
+	formatNone format = iota
+	formatZip
+	formatGzip
+	formatTar
 )
 
-// ExtractFile extracts first file from zip archive
-func ExtractFile(r io.Reader) (io.ReadCloser, error) {
-	buf := new(bytes.Buffer)
-	_, err := io.Copy(buf, r)
+// File is a file extracted from archive to spool
+type File struct {
+	*spool.File
+	name string
+}
+
+// Name returns path of file in archive, path of nested archive is a prefix,
+// e.g. "a.zip/b.tar/c.csv" for c.csv in b.tar.gz in a.zip
+func (f *File) Name() string {
+	return f.name
+}
+
+// Extract extracts files which match any of patterns (path.Match against
+// path in archive or base name, case insensitive) from zip, tar, gzip and
+// tar.gz archive r, nested archives are extracted unless they match,
+// all files are extracted if there are no patterns, name is name of r
+func Extract(r io.Reader, name string, sp spool.Spool, pattern ...string) ([]*File, error) {
+	w := &walker{sp: sp, pattern: pattern}
+	err := w.open(r, name)
 	if err != nil {
+		closeFiles(w.files)
 		return nil, err
 	}
 
-	br := bytes.NewReader(buf.Bytes())
-	zip, err := zip.NewReader(br, br.Size())
+	return w.files, nil
+}
+
+// ExtractOne is Extract which fails unless exactly one file matches
+func ExtractOne(r io.Reader, name string, sp spool.Spool, pattern ...string) (*File, error) {
+	l, err := Extract(r, name, sp, pattern...)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(zip.File) == 0 {
-		return nil, fmt.Errorf("zip: archive is empty: %s", "unreachable")
+	switch len(l) {
+	case 0:
+		return nil, fmt.Errorf("%s: no file matches %v", name, pattern)
+	case 1:
+		return l[0], nil
+	}
+
+	names := make([]string, len(l))
+	for i := range l {
+		names[i] = l[i].Name()
+	}
+	closeFiles(l)
+	return nil, fmt.Errorf("%s: %d files match %v: %s", name, len(l), pattern, strings.Join(names, ", "))
+}
+
+// List returns paths of all files in archive r including files in nested archives
+func List(r io.Reader, name string, sp spool.Spool) ([]string, error) {
+	w := &walker{sp: sp, list: true}
+	err := w.open(r, name)
+	return w.names, err
+}
+
+// IsArchive reports whether name has extension of supported archive
+func IsArchive(name string) bool {
+	n := strings.ToLower(name)
+	for _, v := range []string{".zip", ".gz", ".tgz", ".tar"} {
+		if strings.HasSuffix(n, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func closeFiles(l []*File) {
+	for i := range l {
+		_ = l[i].Close()
+	}
+}
+
+type walker struct {
+	sp      spool.Spool
+	pattern []string
+	list    bool
+	top     string // name of archive, it is not a part of path for patterns
+
+	files []*File
+	names []string
+}
+
+func (w *walker) open(r io.Reader, name string) error {
+	name = path.Base(name)
+	w.top = name
+
+	ra, ok := r.(spool.Sizer)
+	if !ok {
+		f, err := w.sp.Copy(r)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		defer func() { _ = f.Close() }()
+		ra = f
+	}
+
+	return w.walk(ra, name, 0)
+}
+
+func (w *walker) walk(r spool.Sizer, name string, depth int) error {
+	switch detect(r) {
+	case formatZip:
+		return w.walkZip(r, name, depth)
+	case formatGzip:
+		return w.walkGzip(r, name, depth)
+	case formatTar:
+		return w.walkTar(io.NewSectionReader(r, 0, r.Size()), name, depth)
+	}
+	return fmt.Errorf("%s: unknown archive format", name)
+}
+
+func (w *walker) walkZip(r spool.Sizer, name string, depth int) error {
+	z, err := zip.NewReader(r, r.Size())
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	for _, v := range z.File {
+		if v.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := v.Open()
+		if err != nil {
+			return fmt.Errorf("%s/%s: %v", name, v.Name, err)
+		}
+		err = w.entry(rc, name+"/"+v.Name, depth)
+		_ = rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *walker) walkGzip(r spool.Sizer, name string, depth int) error {
+	z, err := gzip.NewReader(io.NewSectionReader(r, 0, r.Size()))
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	defer func() { _ = z.Close() }()
+
+	// the only file replaces archive in path: a.csv.gz -> a.csv, a.tgz -> a.tar
+	switch ext := path.Ext(name); strings.ToLower(ext) {
+	case ".gz":
+		name = strings.TrimSuffix(name, ext)
+	case ".tgz":
+		name = strings.TrimSuffix(name, ext) + ".tar"
+	}
+
+	return w.entry(z, name, depth)
+}
+
+func (w *walker) walkTar(r io.Reader, name string, depth int) error {
+	t := tar.NewReader(r)
+	for {
+		h, err := t.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		err = w.entry(t, name+"/"+h.Name, depth)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// entry keeps file r if it matches, nested archive is walked through
+func (w *walker) entry(r io.Reader, name string, depth int) error {
+	nested := IsArchive(name) && depth < maxDepth
+	if !nested && w.list {
+		w.names = append(w.names, name)
+		return nil
+	}
+
+	matched := w.match(name)
+	if !nested && !matched {
+		return nil
+	}
+
+	f, err := w.sp.Copy(r)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	if nested && !(matched && len(w.pattern) > 0) {
+		defer func() { _ = f.Close() }()
+		return w.walk(f, name, depth+1)
+	}
+
+	w.files = append(w.files, &File{File: f, name: name})
+	return nil
+}
+
+func (w *walker) match(name string) bool {
+	if len(w.pattern) == 0 {
+		return true
+	}
+
+	name = strings.ToLower(strings.TrimPrefix(name, w.top+"/"))
+
+	for _, v := range w.pattern {
+		v = strings.ToLower(v)
+		if ok, _ := path.Match(v, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(v, path.Base(name)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func detect(r io.ReaderAt) format {
+	b := make([]byte, 262)
+	n, _ := r.ReadAt(b, 0)
+	b = b[:n]
+
+	switch {
+	case bytes.HasPrefix(b, []byte("PK\x03\x04")), bytes.HasPrefix(b, []byte("PK\x05\x06")):
+		return formatZip
+	case bytes.HasPrefix(b, []byte{0x1f, 0x8b}):
+		return formatGzip
+	case len(b) >= 262 && string(b[257:262]) == "ustar":
+		return formatTar
 	}
 
-	return zip.File[0].Open()
+	return formatNone
 }
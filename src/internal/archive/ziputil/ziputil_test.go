@@ -0,0 +1,217 @@
+package ziputil
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"internal/store/spool"
+)
+
+// entry is a file of test archive
+type entry struct {
+	name string
+	data []byte
+}
+
+func zipOf(t *testing.T, l ...entry) []byte {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for _, v := range l {
+		f, err := w.Create(v.name)
+		if err == nil {
+			_, err = f.Write(v.data)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarOf(t *testing.T, l ...entry) []byte {
+	buf := new(bytes.Buffer)
+	w := tar.NewWriter(buf)
+	for _, v := range l {
+		err := w.WriteHeader(&tar.Header{Name: v.name, Mode: 0600, Size: int64(len(v.data)), Typeflag: tar.TypeReg})
+		if err == nil {
+			_, err = w.Write(v.data)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipOf(t *testing.T, b []byte) []byte {
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+	_, err := w.Write(b)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testArchive returns zip with readme, csv, zip in zip and tar.gz in zip
+func testArchive(t *testing.T) []byte {
+	return zipOf(t,
+		entry{"readme.txt", []byte("readme")},
+		entry{"data.csv", []byte("a")},
+		entry{"inner.zip", zipOf(t, entry{"b.csv", []byte("b")})},
+		entry{"x.tgz", gzipOf(t, tarOf(t,
+			entry{"c.csv", []byte("c")},
+			entry{"docs/readme.txt", []byte("docs")},
+		))},
+	)
+}
+
+func extract(t *testing.T, b []byte, name string, pattern ...string) map[string]string {
+	l, err := Extract(bytes.NewReader(b), name, spool.Spool{Dir: t.TempDir()}, pattern...)
+	if err != nil {
+		t.Fatalf("%v: %v", pattern, err)
+	}
+	defer closeFiles(l)
+
+	files := make(map[string]string)
+	for _, v := range l {
+		data, err := ioutil.ReadAll(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[v.Name()] = string(data)
+	}
+	return files
+}
+
+func TestExtract(t *testing.T) {
+	b := testArchive(t)
+
+	for _, v := range []struct {
+		pattern []string
+		files   map[string]string
+	}{
+		{[]string{"*.CSV"}, map[string]string{
+			"a.zip/data.csv":        "a",
+			"a.zip/inner.zip/b.csv": "b",
+			"a.zip/x.tar/c.csv":     "c",
+		}},
+		{[]string{"inner.zip/*.csv"}, map[string]string{"a.zip/inner.zip/b.csv": "b"}},
+		{[]string{"x.tar/docs/*", "data.csv"}, map[string]string{
+			"a.zip/data.csv":              "a",
+			"a.zip/x.tar/docs/readme.txt": "docs",
+		}},
+		{[]string{"*.xml"}, map[string]string{}},
+	} {
+		if files := extract(t, b, "/tmp/a.zip", v.pattern...); !reflect.DeepEqual(files, v.files) {
+			t.Errorf("%v: %v", v.pattern, files)
+		}
+	}
+
+	// matched archive is kept as is
+	files := extract(t, b, "a.zip", "inner.zip")
+	if len(files) != 1 || !strings.HasPrefix(files["a.zip/inner.zip"], "PK") {
+		t.Errorf("inner.zip: %v", files)
+	}
+
+	// all files without patterns
+	if files := extract(t, b, "a.zip"); len(files) != 5 {
+		t.Errorf("all: %v", files)
+	}
+}
+
+func TestExtractGzip(t *testing.T) {
+	files := extract(t, gzipOf(t, []byte("1;2")), "prices.csv.gz", "*.csv")
+	if !reflect.DeepEqual(files, map[string]string{"prices.csv": "1;2"}) {
+		t.Errorf("files %v", files)
+	}
+
+	files = extract(t, tarOf(t, entry{"a.csv", []byte("a")}, entry{"b.txt", nil}), "a.tar", "*.csv")
+	if !reflect.DeepEqual(files, map[string]string{"a.tar/a.csv": "a"}) {
+		t.Errorf("files %v", files)
+	}
+}
+
+func TestExtractDepth(t *testing.T) {
+	b := zipOf(t, entry{"a.csv", []byte("a")})
+	for i := 0; i < maxDepth+1; i++ {
+		b = zipOf(t, entry{"n.zip", b})
+	}
+
+	// archive deeper than maxDepth is a plain file
+	files := extract(t, b, "top.zip")
+	if len(files) != 1 || files["top.zip"+strings.Repeat("/n.zip", maxDepth+1)] == "" {
+		t.Errorf("files %v", keys(files))
+	}
+}
+
+func TestExtractOne(t *testing.T) {
+	b := testArchive(t)
+	sp := spool.Spool{Dir: t.TempDir()}
+
+	f, err := ExtractOne(bytes.NewReader(b), "a.zip", sp, "b.csv")
+	if err != nil || f.Name() != "a.zip/inner.zip/b.csv" {
+		t.Fatalf("%v, %v", f, err)
+	}
+	_ = f.Close()
+
+	for _, v := range []struct {
+		pattern string
+		err     string
+	}{
+		{"readme.txt", "2 files match [readme.txt]: a.zip/readme.txt, a.zip/x.tar/docs/readme.txt"},
+		{"*.xml", "no file matches"},
+	} {
+		_, err = ExtractOne(bytes.NewReader(b), "a.zip", sp, v.pattern)
+		if err == nil || !strings.Contains(err.Error(), v.err) {
+			t.Errorf("%s: error %v", v.pattern, err)
+		}
+	}
+
+	_, err = ExtractOne(strings.NewReader("plain text"), "a.zip", sp)
+	if err == nil || !strings.Contains(err.Error(), "unknown archive format") {
+		t.Errorf("error %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	l, err := List(bytes.NewReader(testArchive(t)), "a.zip", spool.Spool{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"a.zip/data.csv",
+		"a.zip/inner.zip/b.csv",
+		"a.zip/readme.txt",
+		"a.zip/x.tar/c.csv",
+		"a.zip/x.tar/docs/readme.txt",
+	}
+	sort.Strings(l)
+	if !reflect.DeepEqual(l, want) {
+		t.Errorf("list %v", l)
+	}
+}
+
+func keys(m map[string]string) []string {
+	l := make([]string, 0, len(m))
+	for k := range m {
+		l = append(l, k)
+	}
+	return l
+}
@@ -14,10 +14,15 @@ func init() {
 	subcommands.Register(run.NewCmdA24(), "")
 	subcommands.Register(run.NewCmdStl(), "")
 	subcommands.Register(run.NewCmdA55(), "")
+	subcommands.Register(run.NewCmdGen(), "")
+	subcommands.Register(run.NewCmdFlush(), "")
+	subcommands.Register(run.NewCmdConv(), "")
+	subcommands.Register(run.NewCmdLedger(), "")
+	subcommands.Register(run.NewCmdDigest(), "")
 	subcommands.Register(run.NewCmdTst(), "")
 }
 
 // Run registers commands in subcommands and execute it
-func Run() int {
-	return int(subcommands.Execute(context.Background()))
+func Run(ctx context.Context) int {
+	return int(subcommands.Execute(ctx))
 }
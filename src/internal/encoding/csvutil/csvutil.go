@@ -5,25 +5,33 @@ import (
 	"io"
 )
 
-// NewRecordChan allows to work with cvs records in a pipe style
+// NewRecordChan allows to work with cvs records in a pipe style, Line is
+// number of the first line of record, Record may be defined with Error
+// (e.g. wrong number of fields). Reading goes on after csv.ParseError, it
+// stops after other errors (e.g. I/O), the pipe must be read to the end
 func NewRecordChan(f io.Reader, comma rune, lquotes bool, skip int) <-chan struct {
 	Record []string
+	Line   int
 	Error  error
 } {
 	var (
 		pipe = make(chan struct {
 			Record []string
+			Line   int
 			Error  error
 		})
-		makeResult = func(rec []string, err error) struct {
+		makeResult = func(rec []string, line int, err error) struct {
 			Record []string
+			Line   int
 			Error  error
 		} {
 			return struct {
 				Record []string
+				Line   int
 				Error  error
 			}{
 				rec,
+				line,
 				err,
 			}
 		}
@@ -43,7 +51,12 @@ func NewRecordChan(f io.Reader, comma rune, lquotes bool, skip int) <-chan struc
 				if err == io.EOF {
 					break
 				}
-				pipe <- makeResult(nil, err)
+				e, ok := err.(*csv.ParseError)
+				if !ok {
+					pipe <- makeResult(nil, 0, err)
+					break
+				}
+				pipe <- makeResult(rec, e.StartLine, err)
 				continue
 			}
 
@@ -51,7 +64,8 @@ func NewRecordChan(f io.Reader, comma rune, lquotes bool, skip int) <-chan struc
 			if n <= skip {
 				continue
 			}
-			pipe <- makeResult(rec, nil)
+			line, _ := r.FieldPos(0)
+			pipe <- makeResult(rec, line, nil)
 		}
 	}()
 
@@ -0,0 +1,63 @@
+package csvutil
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// failingReader returns err after r is read
+type failingReader struct {
+	r   io.Reader
+	err error
+	n   int // reads after r
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		f.n++
+		return n, f.err
+	}
+	return n, err
+}
+
+func TestRecordChan(t *testing.T) {
+	var (
+		lines []int
+		errs  int
+	)
+	for v := range NewRecordChan(strings.NewReader("h1;h2\n1;2\n3\n\"x\"y;4\n5;6\n"), ';', false, 1) {
+		if v.Error != nil {
+			errs++
+			continue
+		}
+		lines = append(lines, v.Line)
+	}
+
+	// short row and bare quote are reported, reading goes on
+	if len(lines) != 2 || lines[0] != 2 || lines[1] != 5 || errs != 2 {
+		t.Errorf("lines %v, errors %d", lines, errs)
+	}
+}
+
+func TestRecordChanReadError(t *testing.T) {
+	r := &failingReader{r: strings.NewReader("1;2\n3;4\n"), err: errors.New("disk failed")}
+
+	var (
+		n    int
+		errs []error
+	)
+	for v := range NewRecordChan(r, ';', false, 0) {
+		if v.Error != nil {
+			errs = append(errs, v.Error)
+			continue
+		}
+		n++
+	}
+
+	if n != 2 || len(errs) != 1 || errs[0] != r.err || r.n != 1 {
+		t.Errorf("records %d, errors %v, reads after error %d", n, errs, r.n)
+	}
+}
@@ -0,0 +1,157 @@
+package dbfutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// Errors of header
+var (
+	ErrUnsupportedVersion = errors.New("dbf: unsupported version")
+	ErrInvalidHeader      = errors.New("dbf: invalid header")
+)
+
+type header struct {
+	Version          uint8
+	Year, Month, Day uint8
+	RecordCount      uint32
+	HeaderLength     uint16
+	RecordByteLength uint16
+	_                [20]byte
+}
+
+// descriptor is a field descriptor of header
+type descriptor struct {
+	Name   [11]byte
+	Type   byte
+	_      [4]byte
+	Length uint8
+	_      [15]byte
+}
+
+type column struct {
+	name     string
+	typ      byte
+	length   int
+	position int // in record, the first byte is deletion flag
+}
+
+// Table reads dBase III records one by one, so memory does not depend on
+// size of file
+type Table struct {
+	r       *bufio.Reader
+	h       header
+	columns []column
+	buf     []byte
+	n       int // records read
+}
+
+// NewTable reads header of table from r, records are read by Next
+func NewTable(r io.Reader) (*Table, error) {
+	t := &Table{r: bufio.NewReader(r)}
+
+	err := binary.Read(t.r, binary.LittleEndian, &t.h)
+	if err != nil {
+		return nil, fmt.Errorf("dbf: %v", err)
+	}
+	if t.h.Version != 3 {
+		return nil, ErrUnsupportedVersion
+	}
+	if t.h.HeaderLength < 33 || t.h.RecordByteLength == 0 {
+		return nil, ErrInvalidHeader
+	}
+
+	pos := 1
+	for i := 0; i < (int(t.h.HeaderLength)-33)/32; i++ {
+		var d descriptor
+		err = binary.Read(t.r, binary.LittleEndian, &d)
+		if err != nil {
+			return nil, fmt.Errorf("dbf: %v", err)
+		}
+		t.columns = append(t.columns, column{columnName(d.Name), d.Type, int(d.Length), pos})
+		pos += int(d.Length)
+	}
+	if pos > int(t.h.RecordByteLength) {
+		return nil, ErrInvalidHeader
+	}
+
+	// terminator and the rest of header
+	_, err = io.CopyN(ioutil.Discard, t.r, int64(t.h.HeaderLength)-32-32*int64(len(t.columns)))
+	if err != nil {
+		return nil, fmt.Errorf("dbf: %v", err)
+	}
+
+	t.buf = make([]byte, t.h.RecordByteLength)
+	return t, nil
+}
+
+// NumberOfRecords returns number of records of header
+func (t *Table) NumberOfRecords() int {
+	return int(t.h.RecordCount)
+}
+
+// ColumnNames returns names of columns in order of table
+func (t *Table) ColumnNames() []string {
+	l := make([]string, len(t.columns))
+	for i := range t.columns {
+		l[i] = t.columns[i].name
+	}
+	return l
+}
+
+// Next returns the next record by column names, io.EOF is returned after
+// the last record, a truncated record ends the table as well. Values are
+// strings without surrounding spaces, time.Time (or nil) for dates and
+// bool for logicals
+func (t *Table) Next() (map[string]interface{}, error) {
+	if t.n >= int(t.h.RecordCount) {
+		return nil, io.EOF
+	}
+
+	_, err := io.ReadFull(t.r, t.buf)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.n++
+
+	m := make(map[string]interface{}, len(t.columns))
+	for _, c := range t.columns {
+		m[c.name] = parseField(c.typ, t.buf[c.position:c.position+c.length])
+	}
+	return m, nil
+}
+
+func columnName(b [11]byte) string {
+	if n := bytes.IndexByte(b[:], 0); n >= 0 {
+		return string(b[:n])
+	}
+	return string(b[:])
+}
+
+func parseField(typ byte, b []byte) interface{} {
+	switch typ {
+	case 'D':
+		if len(strings.TrimSpace(string(b))) == 8 {
+			v, _ := time.Parse("20060102", string(b))
+			return v
+		}
+		return nil
+	case 'L':
+		switch string(b) {
+		case "t", "T", "y", "Y", "1":
+			return true
+		}
+		return false
+	}
+	return strings.TrimSpace(string(b))
+}
@@ -0,0 +1,121 @@
+package dbfutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTable returns dBase III table with columns NAME (C 10), DATE (D 8),
+// CENA (N 6) and records, truncated by cut bytes
+func testTable(t *testing.T, cut int, records ...string) []byte {
+	buf := new(bytes.Buffer)
+	h := header{
+		Version:          3,
+		RecordCount:      uint32(len(records)),
+		HeaderLength:     32 + 3*32 + 1,
+		RecordByteLength: 1 + 10 + 8 + 6,
+	}
+	err := binary.Write(buf, binary.LittleEndian, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name string
+		typ  byte
+		len  uint8
+	}{
+		{"NAME", 'C', 10},
+		{"DATE", 'D', 8},
+		{"CENA", 'N', 6},
+	} {
+		d := descriptor{Type: c.typ, Length: c.len}
+		copy(d.Name[:], c.name)
+		err = binary.Write(buf, binary.LittleEndian, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf.WriteByte(0x0d)
+	for _, r := range records {
+		buf.WriteString(" " + r)
+	}
+	buf.WriteByte(0x1a)
+
+	return buf.Bytes()[:buf.Len()-cut]
+}
+
+func TestTable(t *testing.T) {
+	tbl, err := NewTable(bytes.NewReader(testTable(t, 0,
+		"Aspirin   20261016  12.5",
+		"Bint"+strings.Repeat(" ", 16)+"3,00",
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := tbl.ColumnNames(); len(n) != 3 || n[0] != "NAME" || n[2] != "CENA" {
+		t.Errorf("columns %v", n)
+	}
+
+	r, err := tbl.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r["NAME"] != "Aspirin" || r["DATE"] != time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC) || r["CENA"] != "12.5" {
+		t.Errorf("record %v", r)
+	}
+
+	r, err = tbl.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r["NAME"] != "Bint" || r["DATE"] != nil || r["CENA"] != "3,00" {
+		t.Errorf("record %v", r)
+	}
+
+	if _, err = tbl.Next(); err != io.EOF {
+		t.Errorf("error %v, want EOF", err)
+	}
+}
+
+func TestTableTruncated(t *testing.T) {
+	// the last record is cut, as well as EOF marker
+	tbl, err := NewTable(bytes.NewReader(testTable(t, 3,
+		"Aspirin   20261016  12.5",
+		"Bint"+strings.Repeat(" ", 16)+"3,00",
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	for {
+		_, err = tbl.Next()
+		if err != nil {
+			break
+		}
+		n++
+	}
+	if n != 1 || err != io.EOF {
+		t.Errorf("records %d, error %v", n, err)
+	}
+}
+
+func TestTableHeader(t *testing.T) {
+	b := testTable(t, 0)
+	b[0] = 0x30
+
+	_, err := NewTable(bytes.NewReader(b))
+	if err != ErrUnsupportedVersion {
+		t.Errorf("error %v", err)
+	}
+
+	_, err = NewTable(bytes.NewReader(b[:20]))
+	if err == nil {
+		t.Error("short header is accepted")
+	}
+}
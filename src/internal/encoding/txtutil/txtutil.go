@@ -1,7 +1,9 @@
 package txtutil
 
 import (
+	"fmt"
 	"io"
+	"strings"
 
 	"golang.org/x/text/encoding/charmap"
 	"golang.org/x/text/transform"
@@ -12,6 +14,25 @@ func Win1251ToUTF8(r io.Reader) io.Reader {
 	return transform.NewReader(r, charmap.Windows1251.NewDecoder())
 }
 
+// CP866ToUTF8 decodes text from CP866 (DOS) to UTF8
+func CP866ToUTF8(r io.Reader) io.Reader {
+	return transform.NewReader(r, charmap.CodePage866.NewDecoder())
+}
+
+// ToUTF8 decodes text to UTF8 by encoding name (utf8, win1251, cp866)
+func ToUTF8(r io.Reader, enc string) (io.Reader, error) {
+	switch strings.ToLower(strings.Replace(enc, "-", "", -1)) {
+	case "", "utf8":
+		return r, nil
+	case "win1251", "windows1251", "cp1251":
+		return Win1251ToUTF8(r), nil
+	case "cp866", "ibm866", "dos":
+		return CP866ToUTF8(r), nil
+	}
+
+	return nil, fmt.Errorf("txtutil: unknown encoding '%s'", enc)
+}
+
 /*
    toWin1251 := func(s string) string {
            b := new(bytes.Buffer)
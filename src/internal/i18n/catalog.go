@@ -0,0 +1,137 @@
+package i18n
+
+import (
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message/catalog"
+)
+
+// entry is a message of catalog, key is the English message unless en is
+// defined, e.g. for plural forms
+type entry struct {
+	key        string
+	en, uk, ru catalog.Message
+}
+
+func s(msg string) catalog.Message {
+	return catalog.String(msg)
+}
+
+// one selects English form of argument arg
+func one(arg int, one, other string) catalog.Message {
+	return plural.Selectf(arg, "%d", "one", one, "other", other)
+}
+
+// few selects Ukrainian or Russian form of argument arg, e.g. for 1, 2 and 5
+func few(arg int, one, few, other string) catalog.Message {
+	return plural.Selectf(arg, "%d", "one", one, "few", few, "other", other)
+}
+
+var entries = []entry{
+	// notifications
+	{key: "ERROR [%s]", uk: s("ПОМИЛКА [%s]"), ru: s("ОШИБКА [%s]")},
+	{key: "RECOVERED [%s]", uk: s("ВІДНОВЛЕНО [%s]"), ru: s("ВОССТАНОВЛЕНО [%s]")},
+	{key: "DIGEST [%s]", uk: s("ЗВЕДЕННЯ [%s]"), ru: s("СВОДКА [%s]")},
+	{key: "daily", uk: s("щоденне"), ru: s("ежедневная")},
+	{key: "weekly", uk: s("щотижневе"), ru: s("еженедельная")},
+	{key: "version %s", uk: s("версія %s"), ru: s("версия %s")},
+	{key: "report", uk: s("звіт"), ru: s("отчёт")},
+	{key: "input", uk: s("вхідний файл"), ru: s("входной файл")},
+	{key: "rejects", uk: s("відхилені рядки"), ru: s("отклонённые строки")},
+	{key: "attached", uk: s("додано"), ru: s("приложено")},
+	{key: "log", uk: s("журнал"), ru: s("журнал")},
+	{key: "flags", uk: s("параметри"), ru: s("параметры")},
+	{
+		key: "failing since %s, %d runs",
+		en:  one(2, "failing since %s, %d run", "failing since %s, %d runs"),
+		uk:  few(2, "збій з %s, %d запуск", "збій з %s, %d запуски", "збій з %s, %d запусків"),
+		ru:  few(2, "сбой с %s, %d запуск", "сбой с %s, %d запуска", "сбой с %s, %d запусков"),
+	},
+	{
+		key: "%d repeats not notified",
+		en:  one(1, "%d repeat not notified", "%d repeats not notified"),
+		uk:  few(1, "%d повтор не надіслано", "%d повтори не надіслано", "%d повторів не надіслано"),
+		ru:  few(1, "%d повтор не отправлен", "%d повтора не отправлено", "%d повторов не отправлено"),
+	},
+	{
+		key: "recovered after %d failed runs since %s",
+		en:  one(1, "recovered after %d failed run since %s", "recovered after %d failed runs since %s"),
+		uk:  few(1, "відновлено після %d невдалого запуску з %s", "відновлено після %d невдалих запусків з %s", "відновлено після %d невдалих запусків з %s"),
+		ru:  few(1, "восстановлено после %d неудачного запуска с %s", "восстановлено после %d неудачных запусков с %s", "восстановлено после %d неудачных запусков с %s"),
+	},
+	{key: "last error: %s", uk: s("остання помилка: %s"), ru: s("последняя ошибка: %s")},
+
+	// digest
+	{key: "command", uk: s("команда"), ru: s("команда")},
+	{key: "status", uk: s("стан"), ru: s("состояние")},
+	{key: "last run", uk: s("останній запуск"), ru: s("последний запуск")},
+	{key: "last ok", uk: s("останній успіх"), ru: s("последний успех")},
+	{key: "shops", uk: s("аптеки"), ru: s("аптеки")},
+	{key: "items", uk: s("позиції"), ru: s("позиции")},
+	{key: "ok", uk: s("гаразд"), ru: s("в порядке")},
+	{key: "never", uk: s("ніколи"), ru: s("никогда")},
+	{key: "no runs", uk: s("запусків немає"), ru: s("запусков нет")},
+	{key: "no runs in period", uk: s("немає запусків за період"), ru: s("нет запусков за период")},
+	{
+		key: "failing since %s (%d runs)",
+		en:  one(2, "failing since %s (%d run)", "failing since %s (%d runs)"),
+		uk:  few(2, "збій з %s (%d запуск)", "збій з %s (%d запуски)", "збій з %s (%d запусків)"),
+		ru:  few(2, "сбой с %s (%d запуск)", "сбой с %s (%d запуска)", "сбой с %s (%d запусков)"),
+	},
+	{key: "total: %s, %s", uk: s("усього: %s, %s"), ru: s("всего: %s, %s")},
+	{
+		key: "%d shops",
+		en:  one(1, "%d shop", "%d shops"),
+		uk:  few(1, "%d аптека", "%d аптеки", "%d аптек"),
+		ru:  few(1, "%d аптека", "%d аптеки", "%d аптек"),
+	},
+	{
+		key: "%d items",
+		en:  one(1, "%d item", "%d items"),
+		uk:  few(1, "%d позиція", "%d позиції", "%d позицій"),
+		ru:  few(1, "%d позиция", "%d позиции", "%d позиций"),
+	},
+
+	// reports
+	{key: "%s: read %d, accepted %d, rejected %d", uk: s("%s: прочитано %d, прийнято %d, відхилено %d"), ru: s("%s: прочитано %d, принято %d, отклонено %d")},
+	{key: "decode error", uk: s("помилка декодування"), ru: s("ошибка декодирования")},
+	{key: "short row", uk: s("короткий рядок"), ru: s("короткая строка")},
+	{key: "bad number", uk: s("невірне число"), ru: s("неверное число")},
+	{key: "unknown shop", uk: s("невідома аптека"), ru: s("неизвестная аптека")},
+	{key: "unknown drug", uk: s("невідомий товар"), ru: s("неизвестный товар")},
+	{key: "unknown offer", uk: s("невідома пропозиція"), ru: s("неизвестное предложение")},
+	{key: "failed downloads %d: %s", uk: s("невдалі завантаження %d: %s"), ru: s("неудачные загрузки %d: %s")},
+	{key: "%s and %d more", uk: s("%s і ще %d"), ru: s("%s и ещё %d")},
+	{key: "delivered %d of %d", uk: s("доставлено %d з %d"), ru: s("доставлено %d из %d")},
+	{key: "not delivered", uk: s("не доставлено"), ru: s("не доставлено")},
+	{key: "unchanged %d", uk: s("без змін %d"), ru: s("без изменений %d")},
+
+	// CLI
+	{key: "%s: %s -> %s: dropped %s", uk: s("%s: %s -> %s: відкинуто %s"), ru: s("%s: %s -> %s: отброшено %s")},
+	{key: "forget", uk: s("забуто"), ru: s("забыто")},
+}
+
+var cat = newCatalog()
+
+func newCatalog() *catalog.Catalog {
+	c := catalog.New()
+	for _, v := range entries {
+		for _, m := range []struct {
+			tag language.Tag
+			msg catalog.Message
+		}{
+			{language.English, v.en},
+			{language.Ukrainian, v.uk},
+			{language.Russian, v.ru},
+		} {
+			if m.msg == nil {
+				continue
+			}
+			err := c.Set(m.tag, v.key, m.msg)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+	return c
+}
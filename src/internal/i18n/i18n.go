@@ -0,0 +1,128 @@
+// Package i18n localizes notifications, reports and CLI output, messages are
+// formatted by golang.org/x/text/message with the catalog of the package
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Supported languages, the first one is default
+var supported = []language.Tag{
+	language.English,
+	language.Ukrainian,
+	language.Russian,
+}
+
+var matcher = language.NewMatcher(supported)
+
+// Parse returns supported language for s (e.g. uk, ru-RU or uk_UA.UTF-8),
+// empty s is taken from LC_ALL, LC_MESSAGES or LANG, English is default
+// and replaces unsupported language of environment
+func Parse(s string) (language.Tag, error) {
+	if s == "" {
+		t, err := parse(fromEnv())
+		if err != nil {
+			return supported[0], nil
+		}
+		return t, nil
+	}
+	return parse(s)
+}
+
+func parse(s string) (language.Tag, error) {
+	if s == "" {
+		return supported[0], nil
+	}
+
+	if i := strings.IndexAny(s, ".@"); i >= 0 {
+		s = s[:i] // POSIX locale has encoding and modifier
+	}
+	s = strings.Replace(s, "_", "-", -1)
+
+	if s == "C" || s == "POSIX" {
+		return supported[0], nil
+	}
+
+	t, err := language.Parse(s)
+	if err != nil {
+		return language.Und, fmt.Errorf("lang: %v", err)
+	}
+
+	_, i, c := matcher.Match(t)
+	if c == language.No {
+		return language.Und, fmt.Errorf("lang: unsupported language '%s'", s)
+	}
+	return supported[i], nil
+}
+
+func fromEnv() string {
+	for _, k := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Printer formats messages, numbers and dates for language, it can be used
+// concurrently, nil Printer is English
+type Printer struct {
+	mu  sync.Mutex
+	p   *message.Printer
+	tag language.Tag
+}
+
+var english = NewPrinter(language.English)
+
+// NewPrinter returns printer for tag, see Parse
+func NewPrinter(tag language.Tag) *Printer {
+	return &Printer{
+		p:   message.NewPrinter(tag, message.Catalog(cat)),
+		tag: tag,
+	}
+}
+
+func (p *Printer) or() *Printer {
+	if p == nil {
+		return english
+	}
+	return p
+}
+
+// Lang returns language of printer
+func (p *Printer) Lang() string {
+	return p.or().tag.String()
+}
+
+// Sprintf formats message of catalog by key, key is the format if the
+// message is not found, numbers are formatted for language (1 234,56)
+func (p *Printer) Sprintf(key string, a ...interface{}) string {
+	p = p.or()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.p.Sprintf(key, a...)
+}
+
+// Date formats date for language (02.01.2006)
+func (p *Printer) Date(t time.Time) string {
+	return t.Format(p.or().layout())
+}
+
+// DateTime formats date and time for language (02.01.2006 15:04:05)
+func (p *Printer) DateTime(t time.Time) string {
+	return t.Format(p.or().layout() + " 15:04:05")
+}
+
+func (p *Printer) layout() string {
+	if p.tag == language.English {
+		return "2006-01-02"
+	}
+	return "02.01.2006"
+}
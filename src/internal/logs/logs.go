@@ -0,0 +1,266 @@
+// Package logs is leveled structured logging over log/slog. Lines are text
+// or JSON, levels are set per package (see For), attributes of context (see
+// With) such as run ID, command and stage are added to every line. The
+// standard log package is redirected here at info level
+package logs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options of logging, see Init
+type Options struct {
+	Format string // text or json
+	Level  string // debug, info, warn or error
+	Levels string // levels of packages, e.g. ftpcli=debug,mailcli=debug
+	NoTime bool   // lines without time, e.g. for journald
+}
+
+type config struct {
+	out    slog.Handler // nil if output is discarded
+	tees   map[int]slog.Handler
+	next   int
+	level  slog.Level
+	levels map[string]slog.Level
+}
+
+var (
+	mu  sync.RWMutex
+	cfg = config{level: slog.LevelInfo}
+)
+
+// Init sets output and levels of logging and makes it default of slog and
+// log, w is ioutil.Discard if logs are off
+func Init(w io.Writer, o Options) error {
+	level, err := parseLevel(o.Level)
+	if err != nil {
+		return err
+	}
+
+	levels, err := parseLevels(o.Levels)
+	if err != nil {
+		return err
+	}
+
+	var h slog.Handler
+	if w != ioutil.Discard {
+		ho := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: replaceAttr(o.NoTime)}
+		switch o.Format {
+		case "", "text":
+			h = slog.NewTextHandler(w, ho)
+		case "json":
+			h = slog.NewJSONHandler(w, ho)
+		default:
+			return fmt.Errorf("logs: unknown format '%s'", o.Format)
+		}
+	}
+
+	mu.Lock()
+	cfg.out, cfg.level, cfg.levels = h, level, levels
+	mu.Unlock()
+
+	slog.SetDefault(For(""))
+	return nil
+}
+
+func replaceAttr(noTime bool) func([]string, slog.Attr) slog.Attr {
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if noTime && len(groups) == 0 && a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	err := l.UnmarshalText([]byte(s))
+	if err != nil {
+		return l, fmt.Errorf("logs: %v", err)
+	}
+	return l, nil
+}
+
+func parseLevels(s string) (map[string]slog.Level, error) {
+	m := make(map[string]slog.Level)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+
+		i := strings.Index(v, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("logs: invalid level of package '%s'", v)
+		}
+
+		l, err := parseLevel(v[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		m[v[:i]] = l
+	}
+	return m, nil
+}
+
+// Tee copies lines to w as text until returned func is called, levels
+// are the same as of output
+func Tee(w io.Writer) func() {
+	h := slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if cfg.tees == nil {
+		cfg.tees = make(map[int]slog.Handler)
+	}
+	n := cfg.next
+	cfg.next++
+	cfg.tees[n] = h
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(cfg.tees, n)
+	}
+}
+
+// For returns logger of package pkg, its lines have attribute pkg unless it
+// is empty, see Options.Levels
+func For(pkg string) *slog.Logger {
+	return slog.New(&handler{pkg: pkg})
+}
+
+type ctxKey struct{}
+
+// With returns context whose attributes (key-value pairs as in slog) are
+// added to lines logged with the context, e.g. by Logger.InfoContext
+func With(ctx context.Context, args ...interface{}) context.Context {
+	var l []slog.Attr
+	if v, ok := ctx.Value(ctxKey{}).([]slog.Attr); ok {
+		l = append(l, v...)
+	}
+
+	r := slog.NewRecord(time.Time{}, 0, "", 0)
+	r.Add(args...)
+	r.Attrs(func(a slog.Attr) bool {
+		for i := range l {
+			if l[i].Key == a.Key {
+				l[i] = a // e.g. the next stage
+				return true
+			}
+		}
+		l = append(l, a)
+		return true
+	})
+
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// handler writes lines to output and tees, handlers of them are made on
+// every line as configuration may be changed after loggers are made
+type handler struct {
+	pkg  string
+	ops  []func(slog.Handler) slog.Handler // WithAttrs and WithGroup
+	keys map[string]bool                   // keys of WithAttrs, they win over context
+	grp  bool                              // group is open, context is not merged
+}
+
+func (h *handler) Enabled(_ context.Context, l slog.Level) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if cfg.out == nil && len(cfg.tees) == 0 {
+		return false
+	}
+
+	min, ok := cfg.levels[h.pkg]
+	if !ok {
+		min = cfg.level
+	}
+	return l >= min
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	mu.RLock()
+	l := make([]slog.Handler, 0, 1+len(cfg.tees))
+	if cfg.out != nil {
+		l = append(l, cfg.out)
+	}
+	for _, v := range cfg.tees {
+		l = append(l, v)
+	}
+	mu.RUnlock()
+
+	if a, ok := ctx.Value(ctxKey{}).([]slog.Attr); ok && !h.grp {
+		n := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+		for i := range a {
+			if !h.keys[a[i].Key] {
+				n.AddAttrs(a[i])
+			}
+		}
+		r.Attrs(func(a slog.Attr) bool {
+			n.AddAttrs(a)
+			return true
+		})
+		r = n
+	}
+
+	var errs []error
+	for _, v := range l {
+		if h.pkg != "" {
+			v = v.WithAttrs([]slog.Attr{slog.String("pkg", h.pkg)})
+		}
+		for _, op := range h.ops {
+			v = op(v)
+		}
+		if err := v.Handle(ctx, r.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := h.clone()
+	c.ops = append(c.ops, func(v slog.Handler) slog.Handler { return v.WithAttrs(attrs) })
+	if !c.grp {
+		for i := range attrs {
+			c.keys[attrs[i].Key] = true
+		}
+	}
+	return c
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := h.clone()
+	c.ops = append(c.ops, func(v slog.Handler) slog.Handler { return v.WithGroup(name) })
+	c.grp = true
+	return c
+}
+
+func (h *handler) clone() *handler {
+	c := &handler{
+		pkg:  h.pkg,
+		ops:  append([]func(slog.Handler) slog.Handler(nil), h.ops...),
+		keys: make(map[string]bool, len(h.keys)),
+		grp:  h.grp,
+	}
+	for k := range h.keys {
+		c.keys[k] = true
+	}
+	return c
+}
@@ -0,0 +1,238 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Detect returns wire format of json payload b, json keys of price are
+// lowercase and priceOld has meta with timestamps or data with heads
+func Detect(b []byte) (Format, error) {
+	var v map[string]json.RawMessage
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return "", fmt.Errorf("model: %v", err)
+	}
+
+	if _, ok := v["meta"]; ok {
+		return Price, nil
+	}
+	if _, ok := v["data"]; ok {
+		return Price, nil
+	}
+
+	var p struct {
+		Meta map[string]json.RawMessage
+		Data []map[string]json.RawMessage
+	}
+	err = json.Unmarshal(b, &p)
+	if err != nil {
+		return "", fmt.Errorf("model: %v", err)
+	}
+
+	for _, k := range []string{"Timestamp", "TRangeLower", "TRangeUpper"} {
+		if _, ok := p.Meta[k]; ok {
+			return PriceOld, nil
+		}
+	}
+	if len(p.Data) > 0 {
+		if _, ok := p.Data[0]["Head"]; ok {
+			return PriceOld, nil
+		}
+	}
+
+	return Price1, nil
+}
+
+// Decode returns content of json payload b in format f as sales reports,
+// stock of price and price1 is a report with balance only and priceOld
+// has a report for every shop
+func (f Format) Decode(b []byte) ([]*Sales, error) {
+	switch f {
+	case Price:
+		var v price
+		err := json.Unmarshal(b, &v)
+		if err != nil {
+			return nil, fmt.Errorf("model: %s: %v", f, err)
+		}
+		return []*Sales{fromPrice(v)}, nil
+	case Price1:
+		var v price1
+		err := json.Unmarshal(b, &v)
+		if err != nil {
+			return nil, fmt.Errorf("model: %s: %v", f, err)
+		}
+		return []*Sales{fromPrice1(v)}, nil
+	case PriceOld:
+		var v priceOld
+		err := json.Unmarshal(b, &v)
+		if err != nil {
+			return nil, fmt.Errorf("model: %s: %v", f, err)
+		}
+		return fromPriceOld(v)
+	}
+	return nil, fmt.Errorf("model: unknown wire format '%s'", f)
+}
+
+// Dropped returns names of fields of s which are not empty and can not be
+// represented in format f
+func (f Format) Dropped(s *Sales) []string {
+	var l []string
+	add := func(name string, ok bool) {
+		if ok {
+			l = append(l, name)
+		}
+	}
+
+	switch f {
+	case Price, Price1:
+		add("source", s.Source != "")
+		add("time", !s.Time.IsZero())
+		add("period", !s.From.IsZero() || !s.To.IsZero())
+	case PriceOld:
+		add("shop.id", s.Shop.ID != "")
+		add("shop.head", s.Shop.Head != "")
+		add("shop.addr", s.Shop.Addr != "")
+		add("shop.code", s.Shop.Code != "")
+	}
+
+	var desc, link, quantIn, quantOut, priceIn, markup, amount bool
+	for i := range s.Items {
+		v := &s.Items[i]
+		desc = desc || v.Desc != ""
+		link = link || v.Link != ""
+		quantIn = quantIn || v.QuantIn != 0
+		quantOut = quantOut || v.QuantOut != 0
+		priceIn = priceIn || v.PriceIn != 0
+		markup = markup || v.Markup != 0
+		amount = amount || v.Amount != 0
+	}
+
+	switch f {
+	case Price, PriceOld:
+		add("item.desc", desc)
+		add("item.link", link)
+	}
+	switch f {
+	case Price, Price1:
+		add("item.quantIn", quantIn)
+		add("item.quantOut", quantOut)
+		add("item.priceIn", priceIn)
+		add("item.markup", markup)
+		add("item.amount", amount)
+	}
+
+	return l
+}
+
+func fromPrice(v price) *Sales {
+	s := &Sales{
+		Shop: Shop{
+			ID:   v.Meta.ID,
+			Name: v.Meta.Name,
+			Head: v.Meta.Head,
+			Addr: v.Meta.Addr,
+			Code: v.Meta.Code,
+		},
+		Items: make([]Sale, 0, len(v.Data)),
+	}
+	for i := range v.Data {
+		s.Items = append(s.Items, Sale{
+			Product: Product{
+				ID:   v.Data[i].ID,
+				Name: v.Data[i].Name,
+			},
+			PriceOut: v.Data[i].Price,
+			Balance:  v.Data[i].Quant,
+		})
+	}
+	return s
+}
+
+func fromPrice1(v price1) *Sales {
+	s := &Sales{
+		Shop: Shop{
+			ID:   v.Meta.Code,
+			Name: v.Meta.Name,
+			Head: v.Meta.Head,
+			Addr: v.Meta.Addr,
+			Code: v.Meta.EGRPOU,
+		},
+		Items: make([]Sale, 0, len(v.Data)),
+	}
+	for i := range v.Data {
+		link := v.Data[i].Link
+		if link == "" {
+			link = v.Data[i].Addr
+		}
+		s.Items = append(s.Items, Sale{
+			Product: Product{
+				ID:   v.Data[i].Code,
+				Name: v.Data[i].Name,
+				Desc: v.Data[i].Desc,
+				Link: link,
+			},
+			PriceOut: v.Data[i].Price,
+			Balance:  v.Data[i].Quant,
+		})
+	}
+	return s
+}
+
+func fromPriceOld(v priceOld) ([]*Sales, error) {
+	var (
+		t, from, to time.Time
+		err         error
+	)
+	if t, err = parseTime(v.Meta.Timestamp); err != nil {
+		return nil, err
+	}
+	if from, err = parseTime(v.Meta.TRangeLower); err != nil {
+		return nil, err
+	}
+	if to, err = parseTime(v.Meta.TRangeUpper); err != nil {
+		return nil, err
+	}
+
+	l := make([]*Sales, 0, len(v.Data))
+	for _, d := range v.Data {
+		s := &Sales{
+			Shop:   Shop{Name: d.Head.Drugstore},
+			Source: d.Head.Source,
+			Time:   t,
+			From:   from,
+			To:     to,
+			Items:  make([]Sale, 0, len(d.Item)),
+		}
+		for i := range d.Item {
+			s.Items = append(s.Items, Sale{
+				Product: Product{
+					ID:   d.Item[i].Code,
+					Name: d.Item[i].Drug,
+				},
+				QuantIn:  d.Item[i].QuantInp,
+				QuantOut: d.Item[i].QuantOut,
+				PriceIn:  d.Item[i].PriceInp,
+				PriceOut: d.Item[i].PriceOut,
+				Markup:   d.Item[i].PriceRoc,
+				Balance:  d.Item[i].Balance,
+				Amount:   d.Item[i].BalanceT,
+			})
+		}
+		l = append(l, s)
+	}
+
+	return l, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation("02.01.2006 15:04:05.999999999", s, time.Local)
+	if err != nil {
+		return t, fmt.Errorf("model: %s: %v", PriceOld, err)
+	}
+	return t, nil
+}
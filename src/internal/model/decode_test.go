@@ -0,0 +1,120 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDetect(t *testing.T) {
+	for _, v := range []struct {
+		json string
+		f    Format
+	}{
+		{`{"meta":{"name":"Аптека 1"},"data":[{"id":"10"}]}`, Price},
+		{`{"data":[]}`, Price},
+		{`{"Meta":{"Name":"Аптека 1"},"Data":[{"Code":"10"}]}`, Price1},
+		{`{"Data":[{"Code":"10"}]}`, Price1},
+		{`{}`, Price1},
+		{`{"Meta":{"Timestamp":"16.10.2026 10:00:00"}}`, PriceOld},
+		{`{"Meta":{"TRangeUpper":"16.10.2026 00:00:00"}}`, PriceOld},
+		{`{"Data":[{"Head":{"Drugstore":"Аптека 1"}}]}`, PriceOld},
+		{`[]`, ""},
+		{`{"Meta":[]}`, ""},
+	} {
+		f, err := Detect([]byte(v.json))
+		if v.f == "" && err == nil || v.f != "" && (err != nil || f != v.f) {
+			t.Errorf("%s: %s, %v", v.json, f, err)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, v := range []struct {
+		f    Format
+		json string
+	}{
+		{Price, `{"meta":{"id":"1","name":"Аптека 1","head":"Мережа","addr":"Київ","code":"12345678"},"data":[{"id":"10","name":"Аспирин","quant":5,"price":12.5},{"id":"11","name":"Анальгин"}]}`},
+		{Price1, `{"Meta":{"Code":"1","Name":"Аптека 1","Head":"Мережа","Addr":"Київ","EGRPOU":"12345678"},"Data":[{"Code":"10","Name":"Аспирин","Desc":"таб.","Addr":"https://example.com/10","Link":"https://example.com/10","Quant":5,"Price":12.5}]}`},
+		{PriceOld, `{"Meta":{"Timestamp":"16.10.2026 10:00:00.5","TRangeLower":"01.10.2026 00:00:00","TRangeUpper":"15.10.2026 23:59:59"},"Data":[{"Head":{"Source":"file:sales.zip","Drugstore":"Аптека 1"},"Item":[{"Code":"10","Drug":"Аспирин","QuantInp":10,"QuantOut":5,"PriceInp":10,"PriceOut":12.5,"PriceRoc":25,"Balance":5,"BalanceT":50}]}]}`},
+	} {
+		l, err := v.f.Decode([]byte(v.json))
+		if err != nil {
+			t.Fatalf("%s: %v", v.f, err)
+		}
+		if len(l) != 1 {
+			t.Fatalf("%s: %d reports", v.f, len(l))
+		}
+		if d := v.f.Dropped(l[0]); len(d) != 0 {
+			t.Errorf("%s: dropped %v", v.f, d)
+		}
+
+		p, err := v.f.EncodeSales(l[0])
+		if err != nil {
+			t.Fatalf("%s: %v", v.f, err)
+		}
+		b, err := json.Marshal(p)
+		if err != nil {
+			t.Fatalf("%s: %v", v.f, err)
+		}
+
+		var want, got interface{}
+		_ = json.Unmarshal([]byte(v.json), &want)
+		_ = json.Unmarshal(b, &got)
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("%s:\n got %s\nwant %s", v.f, b, v.json)
+		}
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	for _, v := range []struct {
+		f    Format
+		json string
+	}{
+		{Price, `{"data":{}}`},
+		{Price1, `{"Data":"x"}`},
+		{PriceOld, `{"Meta":{"Timestamp":"2026-10-16"}}`},
+		{"price2", `{}`},
+	} {
+		_, err := v.f.Decode([]byte(v.json))
+		if err == nil {
+			t.Errorf("%s: %s: no error", v.f, v.json)
+		}
+	}
+}
+
+func TestDropped(t *testing.T) {
+	report := &Sales{
+		Shop:   Shop{ID: "1", Name: "Аптека 1", Code: "12345678"},
+		Source: "file:sales.zip",
+		Time:   time.Date(2026, 10, 16, 10, 0, 0, 0, time.Local),
+		Items: []Sale{
+			{Product: Product{ID: "10", Name: "Аспирин", Link: "https://example.com/10"}, QuantIn: 10, Balance: 5},
+			{Product: Product{ID: "11", Name: "Анальгин", Desc: "таб."}, Markup: 25},
+		},
+	}
+	stock := &Sales{
+		Shop:  Shop{Name: "Аптека 1"},
+		Items: []Sale{{Product: Product{ID: "10", Name: "Аспирин"}, PriceOut: 12.5, Balance: 5}},
+	}
+
+	for _, v := range []struct {
+		f     Format
+		s     *Sales
+		names []string
+	}{
+		{Price, report, []string{"source", "time", "item.desc", "item.link", "item.quantIn", "item.markup"}},
+		{Price1, report, []string{"source", "time", "item.quantIn", "item.markup"}},
+		{PriceOld, report, []string{"shop.id", "shop.code", "item.desc", "item.link"}},
+		{Price, stock, nil},
+		{Price1, stock, nil},
+		{PriceOld, stock, nil},
+		{Price, &Sales{To: time.Now()}, []string{"period"}},
+	} {
+		if l := v.f.Dropped(v.s); !reflect.DeepEqual(l, v.names) {
+			t.Errorf("%s: %v, want %v", v.f, l, v.names)
+		}
+	}
+}
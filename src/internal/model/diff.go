@@ -0,0 +1,100 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+)
+
+// Diff is change of stock list since previous one, items are compared by
+// product ID, the last item wins if ID is not unique
+type Diff struct {
+	Shop    Shop
+	Base    string // hash of previous stock list
+	Added   []Stock
+	Changed []Stock // new quantity or price
+	Removed []string
+}
+
+// Hash returns hash of shop and its items regardless of order of items
+func (l *StockList) Hash() string {
+	items := make([]Stock, len(l.Items))
+	copy(items, l.Items)
+	sort.Slice(items, func(i, j int) bool {
+		a, b := &items[i], &items[j]
+		switch {
+		case a.ID != b.ID:
+			return a.ID < b.ID
+		case a.Name != b.Name:
+			return a.Name < b.Name
+		case a.Quant != b.Quant:
+			return a.Quant < b.Quant
+		}
+		return a.Price < b.Price
+	})
+
+	b, _ := json.Marshal(StockList{Shop: l.Shop, Items: items})
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// Compare returns changes from prev to cur
+func Compare(prev, cur *StockList) *Diff {
+	d := &Diff{
+		Shop: cur.Shop,
+		Base: prev.Hash(),
+	}
+
+	old := make(map[string]Stock, len(prev.Items))
+	for _, v := range prev.Items {
+		old[v.ID] = v
+	}
+
+	seen := make(map[string]bool, len(cur.Items))
+	for _, v := range cur.Items {
+		seen[v.ID] = true
+		p, ok := old[v.ID]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, v)
+		case p.Quant != v.Quant || p.Price != v.Price || p.Name != v.Name:
+			d.Changed = append(d.Changed, v)
+		}
+	}
+
+	for _, v := range prev.Items {
+		if !seen[v.ID] {
+			seen[v.ID] = true
+			d.Removed = append(d.Removed, v.ID)
+		}
+	}
+
+	return d
+}
+
+// Len returns number of changed items
+func (d *Diff) Len() int {
+	return len(d.Added) + len(d.Changed) + len(d.Removed)
+}
+
+// priceDiff (v2)
+
+type priceDiff struct {
+	Meta    shop     `json:"meta,omitempty"`
+	Base    string   `json:"base"`
+	Added   []prop   `json:"added,omitempty"`
+	Changed []prop   `json:"changed,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Wire returns diff as priceDiff payload of API v2 ready for json.Marshal
+func (d *Diff) Wire() interface{} {
+	return priceDiff{
+		Meta:    toPrice(&StockList{Shop: d.Shop}).Meta,
+		Base:    d.Base,
+		Added:   toPrice(&StockList{Items: d.Added}).Data,
+		Changed: toPrice(&StockList{Items: d.Changed}).Data,
+		Removed: d.Removed,
+	}
+}
@@ -0,0 +1,75 @@
+// Package model is the canonical model of shops, products, stock and sales,
+// commands fill it and encoders turn it into wire formats of skynet
+package model
+
+import (
+	"time"
+)
+
+// Shop is a drugstore
+type Shop struct {
+	ID   string // ID of shop in source data
+	Name string
+	Head string // chain of drugstores
+	Addr string
+	Code string // EGRPOU
+}
+
+// Product is a drug or any other goods
+type Product struct {
+	ID   string
+	Name string // name with maker
+	Desc string
+	Link string // URL of product page
+}
+
+// Stock is quantity and price of product in shop
+type Stock struct {
+	Product
+	Quant float64
+	Price float64
+}
+
+// StockList is stock of one shop
+type StockList struct {
+	Shop  Shop
+	Items []Stock
+}
+
+// Sale is movement of product in shop during period
+type Sale struct {
+	Product
+	QuantIn  float64
+	QuantOut float64
+	PriceIn  float64
+	PriceOut float64
+	Markup   float64
+	Balance  float64 // quantity at the end of period
+	Amount   float64 // cost of balance
+}
+
+// Sales is sales report of one shop
+type Sales struct {
+	Shop   Shop
+	Source string    // origin of report, e.g. file:name.zip
+	Time   time.Time // time of report
+	From   time.Time // period
+	To     time.Time
+	Items  []Sale
+}
+
+// StockList returns balance at the end of period as stock
+func (s *Sales) StockList() *StockList {
+	l := &StockList{
+		Shop:  s.Shop,
+		Items: make([]Stock, 0, len(s.Items)),
+	}
+	for i := range s.Items {
+		l.Items = append(l.Items, Stock{
+			Product: s.Items[i].Product,
+			Quant:   s.Items[i].Balance,
+			Price:   s.Items[i].PriceOut,
+		})
+	}
+	return l
+}
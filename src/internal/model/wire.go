@@ -0,0 +1,248 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"internal/net/sink"
+)
+
+// Format is wire format of skynet payload
+type Format string
+
+// Wire formats
+const (
+	Price    Format = "price"    // v2: price/shop/prop
+	Price1   Format = "price1"   // v1: price1/shop1/prop1
+	PriceOld Format = "priceOld" // v1: priceOld/meta/data/item
+)
+
+// ParseFormat returns format by name
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case Price, Price1, PriceOld:
+		return f, nil
+	}
+	return "", fmt.Errorf("model: unknown wire format '%s'", s)
+}
+
+// API returns version of skynet API which accepts format (sink.V1 or sink.V2)
+func (f Format) API() int {
+	if f == Price {
+		return sink.V2
+	}
+	return sink.V1
+}
+
+// EncodeStock returns stock list in format f ready for json.Marshal
+func (f Format) EncodeStock(l *StockList) (interface{}, error) {
+	switch f {
+	case Price:
+		return toPrice(l), nil
+	case Price1:
+		return toPrice1(l), nil
+	case PriceOld:
+		return toPriceOld(stockSales(l)), nil
+	}
+	return nil, fmt.Errorf("model: unknown wire format '%s'", f)
+}
+
+// EncodeSales returns sales report in format f ready for json.Marshal,
+// formats without sales get balance at the end of period
+func (f Format) EncodeSales(s *Sales) (interface{}, error) {
+	if f == PriceOld {
+		return toPriceOld(s), nil
+	}
+	return f.EncodeStock(s.StockList())
+}
+
+// price (v2)
+
+type shop struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	Head string `json:"head,omitempty"`
+	Addr string `json:"addr,omitempty"`
+	Code string `json:"code,omitempty"`
+}
+
+type prop struct {
+	ID    string  `json:"id,omitempty"`
+	Name  string  `json:"name,omitempty"`
+	Quant float64 `json:"quant,omitempty"`
+	Price float64 `json:"price,omitempty"`
+}
+
+type price struct {
+	Meta shop   `json:"meta,omitempty"`
+	Data []prop `json:"data,omitempty"`
+}
+
+func toPrice(l *StockList) price {
+	v := price{
+		Meta: shop{
+			ID:   l.Shop.ID,
+			Name: l.Shop.Name,
+			Head: l.Shop.Head,
+			Addr: l.Shop.Addr,
+			Code: l.Shop.Code,
+		},
+		Data: make([]prop, 0, len(l.Items)),
+	}
+	for i := range l.Items {
+		v.Data = append(v.Data, prop{
+			ID:    l.Items[i].ID,
+			Name:  l.Items[i].Name,
+			Quant: l.Items[i].Quant,
+			Price: l.Items[i].Price,
+		})
+	}
+	return v
+}
+
+// price1 (v1)
+
+type shop1 struct {
+	Code   string `json:",omitempty"`
+	Name   string `json:",omitempty"`
+	Head   string `json:",omitempty"`
+	Addr   string `json:",omitempty"`
+	EGRPOU string `json:",omitempty"`
+	File   string `json:",omitempty"`
+}
+
+type prop1 struct {
+	Code  string  `json:",omitempty"`
+	Name  string  `json:",omitempty"`
+	Desc  string  `json:",omitempty"`
+	Addr  string  `json:",omitempty"`
+	Link  string  `json:",omitempty"`
+	Quant float64 `json:",omitempty"`
+	Price float64 `json:",omitempty"`
+}
+
+type price1 struct {
+	Meta shop1   `json:",omitempty"`
+	Data []prop1 `json:",omitempty"`
+}
+
+func toPrice1(l *StockList) price1 {
+	v := price1{
+		Meta: shop1{
+			Code:   l.Shop.ID,
+			Name:   l.Shop.Name,
+			Head:   l.Shop.Head,
+			Addr:   l.Shop.Addr,
+			EGRPOU: l.Shop.Code,
+		},
+		Data: make([]prop1, 0, len(l.Items)),
+	}
+	for i := range l.Items {
+		v.Data = append(v.Data, prop1{
+			Code:  l.Items[i].ID,
+			Name:  l.Items[i].Name,
+			Desc:  l.Items[i].Desc,
+			Addr:  l.Items[i].Link,
+			Link:  l.Items[i].Link,
+			Quant: l.Items[i].Quant,
+			Price: l.Items[i].Price,
+		})
+	}
+	return v
+}
+
+// priceOld (v1)
+
+type meta struct {
+	Timestamp   string `json:",omitempty"`
+	TRangeLower string `json:",omitempty"`
+	TRangeUpper string `json:",omitempty"`
+}
+
+type item struct {
+	Code     string  `json:",omitempty"`
+	Drug     string  `json:",omitempty"`
+	QuantInp float64 `json:",omitempty"`
+	QuantOut float64 `json:",omitempty"`
+	PriceInp float64 `json:",omitempty"`
+	PriceOut float64 `json:",omitempty"`
+	PriceRoc float64 `json:",omitempty"`
+	Balance  float64 `json:",omitempty"`
+	BalanceT float64 `json:",omitempty"`
+}
+
+type head struct {
+	Source    string `json:",omitempty"`
+	Drugstore string `json:",omitempty"`
+}
+
+type data struct {
+	Head head   `json:",omitempty"`
+	Item []item `json:",omitempty"`
+}
+
+type priceOld struct {
+	Meta meta   `json:",omitempty"`
+	Data []data `json:",omitempty"`
+}
+
+func toPriceOld(s *Sales) priceOld {
+	d := data{
+		Head: head{
+			Source:    s.Source,
+			Drugstore: s.Shop.Name,
+		},
+		Item: make([]item, 0, len(s.Items)),
+	}
+	for i := range s.Items {
+		d.Item = append(d.Item, item{
+			Code:     s.Items[i].ID,
+			Drug:     s.Items[i].Name,
+			QuantInp: s.Items[i].QuantIn,
+			QuantOut: s.Items[i].QuantOut,
+			PriceInp: s.Items[i].PriceIn,
+			PriceOut: s.Items[i].PriceOut,
+			PriceRoc: s.Items[i].Markup,
+			Balance:  s.Items[i].Balance,
+			BalanceT: s.Items[i].Amount,
+		})
+	}
+
+	// timestamp is time of payload if report has no time
+	t := s.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	return priceOld{
+		Meta: meta{
+			Timestamp:   t.Format("02.01.2006 15:04:05.999999999"),
+			TRangeLower: formatTime(s.From, "02.01.2006 15:04:05"),
+			TRangeUpper: formatTime(s.To, "02.01.2006 15:04:05"),
+		},
+		Data: []data{d},
+	}
+}
+
+// stockSales returns stock as sales report without movement
+func stockSales(l *StockList) *Sales {
+	s := &Sales{
+		Shop:  l.Shop,
+		Items: make([]Sale, 0, len(l.Items)),
+	}
+	for i := range l.Items {
+		s.Items = append(s.Items, Sale{
+			Product:  l.Items[i].Product,
+			PriceOut: l.Items[i].Price,
+			Balance:  l.Items[i].Quant,
+		})
+	}
+	return s
+}
+
+func formatTime(t time.Time, layout string) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(layout)
+}
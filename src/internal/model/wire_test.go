@@ -0,0 +1,105 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"internal/net/sink"
+)
+
+func testStock() *StockList {
+	return &StockList{
+		Shop: Shop{ID: "1", Name: "Аптека 1", Head: "Мережа", Addr: "Київ", Code: "12345678"},
+		Items: []Stock{
+			{Product: Product{ID: "10", Name: "Аспирин", Desc: "таб.", Link: "https://example.com/10"}, Quant: 5, Price: 12.5},
+			{Product: Product{ID: "11", Name: "Анальгин"}},
+		},
+	}
+}
+
+func TestEncodeStock(t *testing.T) {
+	for _, v := range []struct {
+		f    Format
+		json string
+	}{
+		{Price, `{"meta":{"id":"1","name":"Аптека 1","head":"Мережа","addr":"Київ","code":"12345678"},"data":[{"id":"10","name":"Аспирин","quant":5,"price":12.5},{"id":"11","name":"Анальгин"}]}`},
+		{Price1, `{"Meta":{"Code":"1","Name":"Аптека 1","Head":"Мережа","Addr":"Київ","EGRPOU":"12345678"},"Data":[{"Code":"10","Name":"Аспирин","Desc":"таб.","Addr":"https://example.com/10","Link":"https://example.com/10","Quant":5,"Price":12.5},{"Code":"11","Name":"Анальгин"}]}`},
+	} {
+		p, err := v.f.EncodeStock(testStock())
+		if err != nil {
+			t.Fatalf("%s: %v", v.f, err)
+		}
+		b, err := json.Marshal(p)
+		if err != nil {
+			t.Fatalf("%s: %v", v.f, err)
+		}
+		if string(b) != v.json {
+			t.Errorf("%s:\n got %s\nwant %s", v.f, b, v.json)
+		}
+	}
+
+	if _, err := Format("price2").EncodeStock(testStock()); err == nil {
+		t.Error("unknown format is encoded")
+	}
+}
+
+func TestEncodePriceOld(t *testing.T) {
+	day := time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local)
+	s := &Sales{
+		Shop:   Shop{ID: "1", Name: "Аптека 1"},
+		Source: "file:sales.zip",
+		Time:   day.Add(10*time.Hour + 500*time.Millisecond),
+		From:   day.AddDate(0, 0, -1),
+		To:     day.Add(-time.Second),
+		Items: []Sale{
+			{Product: Product{ID: "10", Name: "Аспирин"}, QuantIn: 10, QuantOut: 5, PriceIn: 10, PriceOut: 12.5, Markup: 25, Balance: 5, Amount: 50},
+		},
+	}
+
+	p, err := PriceOld.EncodeSales(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(p)
+	want := `{"Meta":{"Timestamp":"16.10.2026 10:00:00.5","TRangeLower":"15.10.2026 00:00:00","TRangeUpper":"15.10.2026 23:59:59"},"Data":[{"Head":{"Source":"file:sales.zip","Drugstore":"Аптека 1"},"Item":[{"Code":"10","Drug":"Аспирин","QuantInp":10,"QuantOut":5,"PriceInp":10,"PriceOut":12.5,"PriceRoc":25,"Balance":5,"BalanceT":50}]}]}`
+	if string(b) != want {
+		t.Errorf("\n got %s\nwant %s", b, want)
+	}
+
+	// sales report goes to price and price1 as balance at the end of period
+	p, _ = Price.EncodeSales(s)
+	b, _ = json.Marshal(p)
+	if want := `{"meta":{"id":"1","name":"Аптека 1"},"data":[{"id":"10","name":"Аспирин","quant":5,"price":12.5}]}`; string(b) != want {
+		t.Errorf("\n got %s\nwant %s", b, want)
+	}
+
+	// stock list without time and period is stamped with time of payload
+	p, _ = PriceOld.EncodeStock(testStock())
+	v := p.(priceOld)
+	ts, err := parseTime(v.Meta.Timestamp)
+	if err != nil || time.Since(ts) > time.Minute || v.Meta.TRangeLower != "" || v.Meta.TRangeUpper != "" {
+		t.Errorf("meta %+v, %v", v.Meta, err)
+	}
+	if len(v.Data) != 1 || v.Data[0].Head.Drugstore != "Аптека 1" || len(v.Data[0].Item) != 2 || v.Data[0].Item[0].Balance != 5 || v.Data[0].Item[0].PriceOut != 12.5 {
+		t.Errorf("data %+v", v.Data)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, v := range []struct {
+		s   string
+		api int
+	}{
+		{"price", sink.V2},
+		{"price1", sink.V1},
+		{"priceOld", sink.V1},
+		{"priceold", 0},
+		{"", 0},
+	} {
+		f, err := ParseFormat(v.s)
+		if v.api == 0 && err == nil || v.api != 0 && (err != nil || f.API() != v.api) {
+			t.Errorf("%q: %s, %v", v.s, f, err)
+		}
+	}
+}
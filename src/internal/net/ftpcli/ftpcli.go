@@ -1,24 +1,30 @@
 package ftpcli
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/url"
 	"time"
 
+	"internal/logs"
+	"internal/net/tlsconf"
+	"internal/store/spool"
+
 	"github.com/jlaffaye/ftp"
 )
 
-// Filer is representation for file from FTP
+var logger = logs.For("ftpcli")
+
+// Filer is representation for file from FTP, content is kept in spool
 type Filer interface {
-	io.Reader
+	spool.Reader
 	Name() string
 	Time() time.Time
 }
 
 type file struct {
-	r    io.Reader
+	*spool.File
 	name string
 	time time.Time
 }
@@ -31,11 +37,30 @@ func (f file) Time() time.Time {
 	return f.time
 }
 
-func (f file) Read(p []byte) (int, error) {
-	return f.r.Read(p)
+// conn is a connection to FTP server, see plainConn and ftpsConn
+type conn interface {
+	List(path string) ([]*ftp.Entry, error)
+	Retr(path string) (io.ReadCloser, error)
+	Delete(path string) error
+	Quit() error
+}
+
+// plainConn is a connection without TLS
+type plainConn struct {
+	*ftp.ServerConn
+}
+
+func (c plainConn) Retr(path string) (io.ReadCloser, error) {
+	r, err := c.ServerConn.Retr(path)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
 }
 
-func newFTP(addr string) (*ftp.ServerConn, error) {
+// newFTP connects to server, ftps:// is secured from the start and ftp://
+// with starttls=1 is upgraded by AUTH TLS, see tlsconf
+func newFTP(addr string) (conn, error) {
 	u, err := url.Parse(addr)
 	if err != nil {
 		return nil, err
@@ -48,6 +73,26 @@ func newFTP(addr string) (*ftp.ServerConn, error) {
 	user := u.User.Username()
 	pass, _ := u.User.Password()
 
+	t, err := tlsconf.Parse(u, "ftp")
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Enabled() {
+		c, err := dialFTPS(tlsconf.HostPort(u, t, "21", "990"), t)
+		if err != nil {
+			return nil, err
+		}
+
+		err = c.Login(user, pass)
+		if err != nil {
+			_ = c.Quit()
+			return nil, err
+		}
+
+		return c, nil
+	}
+
 	c, err := ftp.Connect(u.Host)
 	if err != nil {
 		return nil, err
@@ -59,7 +104,7 @@ func newFTP(addr string) (*ftp.ServerConn, error) {
 		return nil, err
 	}
 
-	return c, nil
+	return plainConn{c}, nil
 }
 
 func skipFile(e *ftp.Entry, nameOK func(string) bool) bool {
@@ -69,14 +114,13 @@ func skipFile(e *ftp.Entry, nameOK func(string) bool) bool {
 	return badType || badSize || badName
 }
 
-func copyFileAndClose(dst io.Writer, src io.ReadCloser) error {
+func spoolFileAndClose(sp spool.Spool, src io.ReadCloser) (*spool.File, error) {
 	defer func() { _ = src.Close() }()
-	_, err := io.Copy(dst, src)
-	return err
+	return sp.Copy(src)
 }
 
-// Delete deletes files
-func Delete(addr string, name ...string) error {
+// Delete deletes files, it stops between files when ctx is done
+func Delete(ctx context.Context, addr string, name ...string) error {
 	c, err := newFTP(addr)
 	if err != nil {
 		return err
@@ -84,17 +128,22 @@ func Delete(addr string, name ...string) error {
 	defer func() { _ = c.Quit() }()
 
 	for i := range name {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
 		err = c.Delete(name[i])
 		if err != nil {
 			return err
 		}
+		logger.DebugContext(ctx, "delete", "file", name[i])
 	}
 
 	return nil
 }
 
-// NewFileChan allows to work with files from FTP server in a pipe style
-func NewFileChan(addr string, nameOK func(string) bool, cleanup bool) <-chan struct {
+// NewFileChan allows to work with files from FTP server in a pipe style,
+// files are downloaded to sp, the pipe is closed between files when ctx is done
+func NewFileChan(ctx context.Context, addr string, sp spool.Spool, nameOK func(string) bool, cleanup bool) <-chan struct {
 	File  Filer
 	Error error
 } {
@@ -120,10 +169,10 @@ func NewFileChan(addr string, nameOK func(string) bool, cleanup bool) <-chan str
 		defer func() { close(pipe) }()
 
 		var (
-			c   *ftp.ServerConn
+			c   conn
 			l   []*ftp.Entry
 			r   io.ReadCloser
-			b   *bytes.Buffer
+			f   *spool.File
 			err error
 		)
 
@@ -137,43 +186,60 @@ func NewFileChan(addr string, nameOK func(string) bool, cleanup bool) <-chan str
 		if err != nil {
 			goto fail
 		}
+		logger.DebugContext(ctx, "list", "entries", len(l))
 
 		for _, v := range l {
 			if skipFile(v, nameOK) {
 				continue
 			}
 
+			if err = ctx.Err(); err != nil {
+				goto fail
+			}
+
+			t := time.Now()
 			r, err = c.Retr(v.Name)
 			if err != nil {
 				goto fail
 			}
 
-			b = new(bytes.Buffer)
-			err = copyFileAndClose(b, r)
+			f, err = spoolFileAndClose(sp, r)
 			if err != nil {
+				err = fmt.Errorf("%s: %v", v.Name, err)
 				goto fail
 			}
+			logger.DebugContext(ctx, "retr", "file", v.Name, "bytes", f.Size(), "duration", time.Since(t))
 
 			if cleanup {
 				err = c.Delete(v.Name)
 				if err != nil {
+					_ = f.Close()
 					goto fail
 				}
+				logger.DebugContext(ctx, "delete", "file", v.Name)
 			}
 
-			pipe <- makeResult(
+			select {
+			case pipe <- makeResult(
 				file{
-					r:    b,
+					File: f,
 					name: v.Name,
 					time: v.Time,
 				},
 				nil,
-			)
+			):
+			case <-ctx.Done():
+				_ = f.Close()
+				return
+			}
 		}
 
 		return // success
 	fail:
-		pipe <- makeResult(nil, err)
+		select {
+		case pipe <- makeResult(nil, err):
+		case <-ctx.Done():
+		}
 	}()
 
 	return pipe
@@ -0,0 +1,291 @@
+package ftpcli
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"internal/net/tlsconf"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// ftpsConn is a minimal FTP client over TLS (RFC 4217), jlaffaye/ftp can
+// not secure connections. Data connections are passive and protected
+// (PROT P), TLS session of control connection is reused by them, as
+// many servers require
+type ftpsConn struct {
+	conn net.Conn
+	text *textproto.Conn
+	host string
+	cfg  *tls.Config
+}
+
+func dialFTPS(host string, t tlsconf.Options) (*ftpsConn, error) {
+	cfg, err := t.Config()
+	if err != nil {
+		return nil, err
+	}
+	cfg.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+
+	d := &net.Dialer{Timeout: tlsconf.Timeout}
+	var conn net.Conn
+	if t.Implicit {
+		conn, err = tls.DialWithDialer(d, "tcp", host, cfg)
+	} else {
+		conn, err = d.Dial("tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &ftpsConn{
+		conn: conn,
+		text: textproto.NewConn(conn),
+		host: host,
+		cfg:  cfg,
+	}
+
+	_, _, err = c.cmd(220, "")
+	if err == nil && !t.Implicit {
+		err = c.authTLS()
+	}
+	if err != nil {
+		_ = c.conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// authTLS upgrades plain control connection to TLS
+func (c *ftpsConn) authTLS() error {
+	_, _, err := c.cmd(234, "AUTH TLS")
+	if err != nil {
+		return err
+	}
+
+	s := tls.Client(c.conn, c.cfg)
+	_ = s.SetDeadline(time.Now().Add(tlsconf.Timeout))
+	err = s.Handshake()
+	if err != nil {
+		return err
+	}
+
+	c.conn = s
+	c.text = textproto.NewConn(s)
+	return nil
+}
+
+// cmd sends command (nothing if format is empty) and reads response, code
+// of response must be expectCode, see textproto.Reader.ReadResponse
+func (c *ftpsConn) cmd(expectCode int, format string, args ...interface{}) (int, string, error) {
+	_ = c.conn.SetDeadline(time.Now().Add(tlsconf.Timeout))
+	if format != "" {
+		err := c.text.PrintfLine(format, args...)
+		if err != nil {
+			return 0, "", err
+		}
+	}
+	return c.text.ReadResponse(expectCode)
+}
+
+// Login logs in and protects data connections
+func (c *ftpsConn) Login(user, pass string) error {
+	code, msg, err := c.cmd(0, "USER %s", user)
+	if err != nil {
+		return err
+	}
+
+	switch code {
+	case ftp.StatusLoggedIn:
+	case ftp.StatusUserOK:
+		_, _, err = c.cmd(ftp.StatusLoggedIn, "PASS %s", pass)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%d %s", code, msg)
+	}
+
+	for _, v := range []string{"PBSZ 0", "PROT P", "TYPE I"} {
+		_, _, err = c.cmd(ftp.StatusCommandOK, "%s", v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// data opens protected passive data connection and sends command
+func (c *ftpsConn) data(format string, args ...interface{}) (net.Conn, error) {
+	_, msg, err := c.cmd(ftp.StatusPassiveMode, "PASV")
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := pasvPort(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// the address of PASV is ignored as jlaffaye/ftp does
+	h, _, _ := net.SplitHostPort(c.host)
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(h, strconv.Itoa(port)), tlsconf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, err = c.cmd(1, format, args...) // 125 or 150
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	s := tls.Client(conn, c.cfg)
+	_ = s.SetDeadline(time.Now().Add(tlsconf.Timeout))
+	err = s.Handshake()
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return idleConn{s}, nil
+}
+
+// idleTimeout is max time to wait for data of data connection
+var idleTimeout = tlsconf.Timeout
+
+// idleConn fails read which waits for data longer than idleTimeout, so a
+// stalled transfer does not hang, a long one is not interrupted
+type idleConn struct {
+	net.Conn
+}
+
+func (c idleConn) Read(p []byte) (int, error) {
+	_ = c.Conn.SetDeadline(time.Now().Add(idleTimeout))
+	return c.Conn.Read(p)
+}
+
+// pasvPort returns port of PASV response "Entering Passive Mode (h1,h2,h3,h4,p1,p2)"
+func pasvPort(msg string) (int, error) {
+	i, j := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if i < 0 || j < i {
+		return 0, fmt.Errorf("ftp: invalid PASV response '%s'", msg)
+	}
+
+	f := strings.Split(msg[i+1:j], ",")
+	if len(f) != 6 {
+		return 0, fmt.Errorf("ftp: invalid PASV response '%s'", msg)
+	}
+
+	p1, err1 := strconv.Atoi(strings.TrimSpace(f[4]))
+	p2, err2 := strconv.Atoi(strings.TrimSpace(f[5]))
+	if err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("ftp: invalid PASV response '%s'", msg)
+	}
+
+	return p1<<8 | p2, nil
+}
+
+// List lists directory by NLST, SIZE and MDTM, which are supported more
+// widely than MLSD, entries without size are folders
+func (c *ftpsConn) List(path string) ([]*ftp.Entry, error) {
+	cmd := "NLST " + path
+	if path == "." {
+		cmd = "NLST" // some servers prefix names with path
+	}
+
+	r, err := c.data("%s", cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadAll(r)
+	_ = r.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, err = c.cmd(ftp.StatusClosingDataConnection, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var l []*ftp.Entry
+	for _, name := range strings.Split(string(b), "\n") {
+		name = strings.TrimRight(name, "\r")
+		if name == "" {
+			continue
+		}
+
+		e := &ftp.Entry{Name: name, Type: ftp.EntryTypeFolder}
+
+		code, msg, err := c.cmd(0, "SIZE %s", name)
+		if err != nil {
+			return nil, err
+		}
+		if code == ftp.StatusFile {
+			e.Type = ftp.EntryTypeFile
+			e.Size, _ = strconv.ParseUint(strings.TrimSpace(msg), 10, 64)
+		}
+
+		code, msg, err = c.cmd(0, "MDTM %s", name)
+		if err != nil {
+			return nil, err
+		}
+		if code == ftp.StatusFile {
+			e.Time, _ = time.Parse("20060102150405", strings.TrimSpace(msg))
+		}
+
+		l = append(l, e)
+	}
+
+	return l, nil
+}
+
+// Retr returns content of file, the reader must be closed
+func (c *ftpsConn) Retr(path string) (io.ReadCloser, error) {
+	r, err := c.data("RETR %s", path)
+	if err != nil {
+		return nil, err
+	}
+	return &ftpsResponse{Conn: r, c: c}, nil
+}
+
+// Delete deletes file
+func (c *ftpsConn) Delete(path string) error {
+	_, _, err := c.cmd(ftp.StatusRequestedFileActionOK, "DELE %s", path)
+	return err
+}
+
+// Quit closes connection
+func (c *ftpsConn) Quit() error {
+	_, _, err := c.cmd(ftp.StatusClosing, "QUIT")
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ftpsResponse is data connection of RETR, Close waits for end of transfer
+type ftpsResponse struct {
+	net.Conn
+	c *ftpsConn
+}
+
+func (r *ftpsResponse) Close() error {
+	err := r.Conn.Close()
+	_, _, cerr := r.c.cmd(ftp.StatusClosingDataConnection, "")
+	if err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,360 @@
+package ftpcli
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"internal/net/tlsconf/tlstest"
+	"internal/store/spool"
+)
+
+// ftpsStandIn is an in-process FTPS server which knows just enough of
+// RFC 959 and RFC 4217 for ftpsConn, files are kept in memory
+type ftpsStandIn struct {
+	ln       net.Listener
+	cfg      *tls.Config
+	ca       string
+	implicit bool
+	stall    bool // RETR sends no data
+
+	mu      sync.Mutex
+	files   map[string]string
+	dirs    []string
+	cmds    []string
+	resumed []bool // TLS sessions of data connections are resumed
+}
+
+func newFTPSStandIn(t *testing.T, implicit bool, files map[string]string, dirs ...string) *ftpsStandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &ftpsStandIn{ln: ln, implicit: implicit, files: files, dirs: dirs}
+	s.cfg, s.ca = tlstest.Server(t)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+
+	return s
+}
+
+func (s *ftpsStandIn) addr(user, query string) string {
+	scheme := "ftp"
+	if s.implicit {
+		scheme = "ftps"
+	}
+	return scheme + "://" + user + ":pass@" + s.ln.Addr().String() + "?ca=" + s.ca + query
+}
+
+// commands returns commands which start with prefix
+func (s *ftpsStandIn) commands(prefix string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var l []string
+	for _, v := range s.cmds {
+		if strings.HasPrefix(v, prefix) {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+func (s *ftpsStandIn) serve(conn net.Conn) {
+	if s.implicit {
+		conn = tls.Server(conn, s.cfg)
+	}
+	defer func() { _ = conn.Close() }()
+
+	var (
+		text = textproto.NewConn(conn)
+		pasv net.Listener
+	)
+	reply := func(code int, msg string) {
+		_ = text.PrintfLine("%d %s", code, msg)
+	}
+	defer func() {
+		if pasv != nil {
+			_ = pasv.Close()
+		}
+	}()
+
+	reply(220, "stand-in ready")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i > 0 {
+			cmd, arg = line[:i], line[i+1:]
+		}
+
+		s.mu.Lock()
+		s.cmds = append(s.cmds, line)
+		data, ok := s.files[arg]
+		s.mu.Unlock()
+
+		switch cmd {
+		case "AUTH":
+			reply(234, "AUTH TLS ok")
+			conn = tls.Server(conn, s.cfg)
+			text = textproto.NewConn(conn)
+		case "USER":
+			if arg == "anonymous" {
+				reply(530, "not allowed")
+				break
+			}
+			reply(331, "password required")
+		case "PASS":
+			reply(230, "logged in")
+		case "PBSZ", "PROT", "TYPE":
+			reply(200, "ok")
+		case "PASV":
+			pasv, err = net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				reply(425, err.Error())
+				break
+			}
+			p := pasv.Addr().(*net.TCPAddr).Port
+			reply(227, fmt.Sprintf("Entering Passive Mode (10,0,0,1,%d,%d)", p>>8, p&0xff))
+		case "NLST", "RETR":
+			if cmd == "RETR" && !ok {
+				reply(550, "no such file")
+				break
+			}
+			reply(150, "opening data connection")
+			if cmd == "NLST" {
+				var l []string
+				s.mu.Lock()
+				for k := range s.files {
+					l = append(l, k)
+				}
+				l = append(l, s.dirs...)
+				s.mu.Unlock()
+				sort.Strings(l)
+				data = strings.Join(l, "\r\n") + "\r\n"
+			}
+			if s.transfer(pasv, data, cmd == "RETR" && s.stall) {
+				reply(226, "transfer complete")
+			} else {
+				reply(426, "transfer aborted")
+			}
+		case "SIZE", "MDTM":
+			if !ok {
+				reply(550, "not a plain file")
+				break
+			}
+			if cmd == "SIZE" {
+				reply(213, fmt.Sprint(len(data)))
+			} else {
+				reply(213, "20261016100000")
+			}
+		case "DELE":
+			if !ok {
+				reply(550, "no such file")
+				break
+			}
+			s.mu.Lock()
+			delete(s.files, arg)
+			s.mu.Unlock()
+			reply(250, "deleted")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			reply(502, "not implemented")
+		}
+	}
+}
+
+// transfer sends data over protected data connection, with stall it waits
+// until client closes the connection
+func (s *ftpsStandIn) transfer(pasv net.Listener, data string, stall bool) bool {
+	if pasv == nil {
+		return false
+	}
+	dc, err := pasv.Accept()
+	if err != nil {
+		return false
+	}
+	c := tls.Server(dc, s.cfg)
+	defer func() { _ = c.Close() }()
+
+	err = c.Handshake()
+	if err != nil {
+		return false
+	}
+	s.mu.Lock()
+	s.resumed = append(s.resumed, c.ConnectionState().DidResume)
+	s.mu.Unlock()
+
+	if stall {
+		_, _ = ioutil.ReadAll(c)
+		return false
+	}
+	_, err = c.Write([]byte(data))
+	return err == nil
+}
+
+func readAll(t *testing.T, addr string, nameOK func(string) bool, cleanup bool) (map[string]string, error) {
+	files := make(map[string]string)
+	var err error
+	for v := range NewFileChan(context.Background(), addr, spool.Spool{Dir: t.TempDir()}, nameOK, cleanup) {
+		if v.Error != nil {
+			err = v.Error
+			continue
+		}
+		b, rerr := ioutil.ReadAll(v.File)
+		_ = v.File.Close()
+		if rerr != nil {
+			t.Fatal(rerr)
+		}
+		if v.File.Time().Format("2006-01-02") != "2026-10-16" {
+			t.Errorf("%s: time %v", v.File.Name(), v.File.Time())
+		}
+		files[v.File.Name()] = string(b)
+	}
+	return files, err
+}
+
+func TestFTPS(t *testing.T) {
+	for _, implicit := range []bool{true, false} {
+		s := newFTPSStandIn(t, implicit, map[string]string{
+			"a.csv":     "1;2;3",
+			"b.txt":     "readme",
+			"empty.csv": "",
+		}, "sub.csv")
+
+		query := ""
+		if !implicit {
+			query = "&starttls=1"
+		}
+		files, err := readAll(t, s.addr("user", query), func(name string) bool { return strings.HasSuffix(name, ".csv") }, true)
+		if err != nil {
+			t.Fatalf("implicit %v: %v", implicit, err)
+		}
+		if len(files) != 1 || files["a.csv"] != "1;2;3" {
+			t.Errorf("implicit %v: files %v", implicit, files)
+		}
+
+		// folders and empty files are skipped, a.csv is deleted by cleanup
+		if l := s.commands("RETR"); len(l) != 1 || l[0] != "RETR a.csv" {
+			t.Errorf("implicit %v: %v", implicit, l)
+		}
+		if l := s.commands("DELE"); len(l) != 1 {
+			t.Errorf("implicit %v: %v", implicit, l)
+		}
+		for _, v := range []string{"PBSZ 0", "PROT P", "NLST"} {
+			if len(s.commands(v)) != 1 {
+				t.Errorf("implicit %v: no %s in %v", implicit, v, s.commands(""))
+			}
+		}
+		if (len(s.commands("AUTH TLS")) == 1) == implicit {
+			t.Errorf("implicit %v: %v", implicit, s.commands(""))
+		}
+
+		s.mu.Lock()
+		resumed := s.resumed
+		s.mu.Unlock()
+		if len(resumed) != 2 || !resumed[0] || !resumed[1] {
+			t.Errorf("implicit %v: TLS sessions of data connections are resumed %v", implicit, resumed)
+		}
+	}
+}
+
+func TestFTPSDelete(t *testing.T) {
+	s := newFTPSStandIn(t, true, map[string]string{"a.csv": "1", "b.csv": "2"})
+
+	err := Delete(context.Background(), s.addr("user", ""), "a.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Delete(context.Background(), s.addr("user", ""), "c.csv")
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Errorf("error %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.files["a.csv"]; ok || len(s.files) != 1 {
+		t.Errorf("files %v", s.files)
+	}
+}
+
+func TestFTPSErrors(t *testing.T) {
+	s := newFTPSStandIn(t, true, map[string]string{"a.csv": "1"})
+
+	for _, v := range []struct {
+		addr string
+		err  string
+	}{
+		{s.addr("anonymous", ""), "530 not allowed"},
+		{strings.Replace(s.addr("user", ""), "ca=", "servername=example.com&ca=", 1), "example.com"},
+		{"ftps://user:pass@" + s.ln.Addr().String(), "certificate"},
+		{s.addr("user", "&starttls=1"), "starttls is not allowed"},
+	} {
+		_, err := readAll(t, v.addr, nil, false)
+		if err == nil || !strings.Contains(err.Error(), v.err) {
+			t.Errorf("%s: error %v, want %s", v.addr, err, v.err)
+		}
+	}
+}
+
+func TestFTPSStall(t *testing.T) {
+	defer func(d time.Duration) { idleTimeout = d }(idleTimeout)
+	idleTimeout = 100 * time.Millisecond
+
+	s := newFTPSStandIn(t, true, map[string]string{"a.csv": "1;2;3"})
+	s.stall = true
+
+	done := make(chan error)
+	go func() {
+		_, err := readAll(t, s.addr("user", ""), nil, false)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "timeout") {
+			t.Errorf("error %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("stalled transfer hangs")
+	}
+}
+
+func TestPasvPort(t *testing.T) {
+	for _, v := range []struct {
+		msg  string
+		port int
+	}{
+		{"Entering Passive Mode (127,0,0,1,195,80)", 50000},
+		{"Entering Passive Mode (127, 0, 0, 1, 0, 21).", 21},
+		{"Entering Passive Mode", -1},
+		{"Entering Passive Mode (127,0,0,1,195)", -1},
+		{"Entering Passive Mode (127,0,0,1,x,80)", -1},
+	} {
+		p, err := pasvPort(v.msg)
+		if v.port < 0 && err == nil || v.port >= 0 && (err != nil || p != v.port) {
+			t.Errorf("%s: port %d, error %v", v.msg, p, err)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
-	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -12,8 +11,7 @@ import (
 )
 
 var (
-	cli  *http.Client
-	ctx1 context.Context
+	cli *http.Client
 )
 
 func init() {
@@ -26,53 +24,62 @@ func init() {
 	}
 }
 
-func DoWithTimeout(m, url string, d time.Duration, data io.Reader, h ...string) (int, http.Header, io.Reader, error) {
-	req, err := http.NewRequest(m, url, data)
+// DoWithTimeout makes request which is canceled by ctx or after d
+func DoWithTimeout(ctx context.Context, m, url string, d time.Duration, data io.Reader, h ...string) (int, http.Header, io.Reader, error) {
+	code, head, body, err := OpenWithTimeout(ctx, m, url, d, data, h...)
 	if err != nil {
 		return 0, nil, nil, err
 	}
+	defer closeBody(body)
 
-	if d > 0 {
-		ctx, cancel := context.WithTimeout(context.Background(), d)
-		defer cancel()
-		req = req.WithContext(ctx)
+	buf := new(bytes.Buffer)
+	_, err = buf.ReadFrom(body)
+	if err != nil {
+		return 0, nil, nil, err
 	}
 
-	makeHeader(req.Header, h...)
+	return code, head, buf, nil
+}
 
-	res, err := cli.Do(req)
-	if res != nil {
-		defer closeBody(res.Body)
+// OpenWithTimeout is DoWithTimeout which returns unread body, the body must
+// be closed, d limits the whole request including reading of body
+func OpenWithTimeout(ctx context.Context, m, url string, d time.Duration, data io.Reader, h ...string) (int, http.Header, io.ReadCloser, error) {
+	cancel := context.CancelFunc(func() {})
+	if d > 0 {
+		ctx, cancel = context.WithTimeout(ctx, d)
 	}
+
+	req, err := http.NewRequestWithContext(ctx, m, url, data)
 	if err != nil {
+		cancel()
 		return 0, nil, nil, err
 	}
 
-	buf := new(bytes.Buffer)
-	_, err = buf.ReadFrom(res.Body)
+	makeHeader(req.Header, h...)
+
+	res, err := cli.Do(req)
 	if err != nil {
+		cancel()
 		return 0, nil, nil, err
 	}
 
-	return res.StatusCode, res.Header, buf, nil
+	return res.StatusCode, res.Header, &body{ReadCloser: res.Body, cancel: cancel}, nil
 }
 
-func DoWithTimeoutAndMust2xx(m, url string, t time.Duration, data io.Reader, h ...string) (http.Header, io.Reader, error) {
-	code, head, body, err := DoWithTimeout(m, url, t, data, h...)
-	if err != nil {
-		return nil, nil, err
-	}
+// body cancels request context when it is closed
+type body struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
 
-	if code < http.StatusOK || code > http.StatusIMUsed {
-		buf := new(bytes.Buffer)
-		_, err = buf.ReadFrom(body)
-		if err != nil {
-			return nil, nil, err
-		}
-		return nil, nil, fmt.Errorf("request failed with code %d and msg: %s", code, buf.String())
-	}
+func (b *body) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
 
-	return head, body, nil
+// DoWithTimeoutAndMust2xx makes exactly one attempt and fails if code is not 2xx
+func DoWithTimeoutAndMust2xx(ctx context.Context, m, url string, t time.Duration, data io.Reader, h ...string) (http.Header, io.Reader, error) {
+	return do2xx(ctx, m, url, t, data, h...)
 }
 
 func closeBody(c io.Closer) {
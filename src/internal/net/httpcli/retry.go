@@ -0,0 +1,246 @@
+package httpcli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"time"
+
+	"internal/logs"
+)
+
+var logger = logs.For("httpcli")
+
+// maxErrorMsg limits body of failed response which is kept in error
+const maxErrorMsg = 4096
+
+// Policy describes how failed requests are retried
+type Policy struct {
+	Attempts int           // max number of attempts, 0 or 1 means no retry
+	MinWait  time.Duration // backoff before second attempt
+	MaxWait  time.Duration // max backoff between attempts
+	MaxTime  time.Duration // max total time of all attempts, 0 means no limit
+	Force    bool          // retry non-idempotent request (e.g. POST) as well
+}
+
+// NoRetry makes exactly one attempt
+var NoRetry = Policy{Attempts: 1}
+
+// DefaultPolicy is used by commands if nothing is defined
+var DefaultPolicy = Policy{
+	Attempts: 3,
+	MinWait:  time.Second,
+	MaxWait:  30 * time.Second,
+	MaxTime:  5 * time.Minute,
+}
+
+// Marked returns copy of policy which allows to retry non-idempotent request
+func (p Policy) Marked() Policy {
+	p.Force = true
+	return p
+}
+
+type statusError struct {
+	code  int
+	msg   string
+	after time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("request failed with code %d and msg: %s", e.code, e.msg)
+}
+
+// DoWithRetry is DoWithTimeoutAndMust2xx which retries request with exponential
+// backoff and jitter on network errors, 408, 429 and 5xx (except 501),
+// Retry-After is honored on 429 and 503
+func DoWithRetry(ctx context.Context, p Policy, m, url string, d time.Duration, data io.Reader, h ...string) (http.Header, io.Reader, error) {
+	return retry(ctx, p, m, url, data, func(r io.Reader) (http.Header, io.ReadCloser, error) {
+		head, res, err := do2xx(ctx, m, url, d, r, h...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return head, ioutil.NopCloser(res), nil
+	})
+}
+
+// OpenWithRetry is DoWithRetry which returns unread body (e.g. large file),
+// the body must be closed, reading of body is not retried
+func OpenWithRetry(ctx context.Context, p Policy, m, url string, d time.Duration, data io.Reader, h ...string) (http.Header, io.ReadCloser, error) {
+	return retry(ctx, p, m, url, data, func(r io.Reader) (http.Header, io.ReadCloser, error) {
+		return open2xx(ctx, m, url, d, r, h...)
+	})
+}
+
+func retry(ctx context.Context, p Policy, m, url string, data io.Reader, do func(io.Reader) (http.Header, io.ReadCloser, error)) (http.Header, io.ReadCloser, error) {
+	var body []byte
+	if data != nil {
+		var err error
+		body, err = ioutil.ReadAll(data)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var (
+		t    = time.Now()
+		wait = p.MinWait
+		err  error
+	)
+	for n := 1; ; n++ {
+		var r io.Reader
+		if data != nil {
+			r = bytes.NewReader(body)
+		}
+
+		head, res, err1 := do(r)
+		if err1 == nil {
+			return head, res, nil
+		}
+		err = err1
+
+		if n >= p.Attempts || ctx.Err() != nil || !canRetry(p, m, err) {
+			break
+		}
+
+		s := backoff(wait, p.MaxWait)
+		if e, ok := err.(*statusError); ok && e.after > 0 {
+			s = e.after
+		}
+		if p.MaxTime > 0 && time.Since(t)+s > p.MaxTime {
+			break
+		}
+
+		logger.WarnContext(ctx, "retry", "method", m, "url", logURL(url), "attempt", n+1, "wait", s, "err", logError(err))
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(s):
+		}
+		wait *= 2
+	}
+
+	return nil, nil, err
+}
+
+// logURL returns scheme and host of url for logs which go to notifications,
+// credentials, path (e.g. of webhook) and query may be secrets
+func logURL(s string) string {
+	u, err := neturl.Parse(s)
+	if err != nil {
+		return "xxxxx"
+	}
+	return (&neturl.URL{Scheme: u.Scheme, Host: u.Host}).String()
+}
+
+// logError drops URL which net/http puts into error, see logURL
+func logError(err error) error {
+	if e, ok := err.(*neturl.Error); ok {
+		return fmt.Errorf("%s: %v", e.Op, e.Err)
+	}
+	return err
+}
+
+func do2xx(ctx context.Context, m, url string, d time.Duration, data io.Reader, h ...string) (http.Header, io.Reader, error) {
+	head, body, err := open2xx(ctx, m, url, d, data, h...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeBody(body)
+
+	buf := new(bytes.Buffer)
+	_, err = buf.ReadFrom(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return head, buf, nil
+}
+
+func open2xx(ctx context.Context, m, url string, d time.Duration, data io.Reader, h ...string) (http.Header, io.ReadCloser, error) {
+	code, head, body, err := OpenWithTimeout(ctx, m, url, d, data, h...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if code < http.StatusOK || code > http.StatusIMUsed {
+		defer closeBody(body)
+		buf := new(bytes.Buffer)
+		_, err = buf.ReadFrom(io.LimitReader(body, maxErrorMsg))
+		if err != nil {
+			return nil, nil, err
+		}
+		e := &statusError{code: code, msg: buf.String()}
+		if code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable {
+			e.after = parseRetryAfter(head.Get("Retry-After"))
+		}
+		return nil, nil, e
+	}
+
+	return head, body, nil
+}
+
+func canRetry(p Policy, m string, err error) bool {
+	if !p.Force && !idempotent(m) {
+		return false
+	}
+
+	e, ok := err.(*statusError)
+	if !ok {
+		return true // network error
+	}
+
+	switch {
+	case e.code == http.StatusRequestTimeout, e.code == http.StatusTooManyRequests:
+		return true
+	case e.code == http.StatusNotImplemented:
+		return false
+	case e.code >= 500:
+		return true
+	}
+
+	return false
+}
+
+func idempotent(m string) bool {
+	switch m {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// backoff returns wait with "equal jitter" limited by max
+func backoff(wait, max time.Duration) time.Duration {
+	if wait <= 0 {
+		wait = time.Second
+	}
+	if max > 0 && wait > max {
+		wait = max
+	}
+	half := wait / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func parseRetryAfter(s string) time.Duration {
+	if s == "" {
+		return 0
+	}
+
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+
+	if t, err := http.ParseTime(s); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,121 @@
+package httpcli
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flaky returns server which replies code (with Retry-After if after is
+// not empty) to the first fails requests and 200 after
+func flaky(t *testing.T, fails, code int, after string) (*httptest.Server, func() []time.Time) {
+	var (
+		mu sync.Mutex
+		at []time.Time
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		at = append(at, time.Now())
+		n := len(at)
+		mu.Unlock()
+
+		b, _ := ioutil.ReadAll(r.Body)
+		if n <= fails {
+			if after != "" {
+				w.Header().Set("Retry-After", after)
+			}
+			http.Error(w, "fail", code)
+			return
+		}
+		_, _ = w.Write(b)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]time.Time(nil), at...)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	p := Policy{Attempts: 3, MinWait: time.Millisecond, MaxWait: 10 * time.Millisecond}
+
+	for _, v := range []struct {
+		m     string
+		p     Policy
+		fails int
+		code  int
+		n     int // number of requests
+		ok    bool
+	}{
+		{"GET", p, 2, http.StatusServiceUnavailable, 3, true},
+		{"PUT", p, 1, http.StatusRequestTimeout, 2, true},
+		{"GET", p, 5, http.StatusBadGateway, 3, false},
+		{"GET", p, 1, http.StatusNotImplemented, 1, false},
+		{"GET", p, 1, http.StatusBadRequest, 1, false},
+		{"POST", p, 1, http.StatusServiceUnavailable, 1, false}, // not idempotent
+		{"POST", p.Marked(), 1, http.StatusServiceUnavailable, 2, true},
+		{"GET", NoRetry, 1, http.StatusServiceUnavailable, 1, false},
+	} {
+		srv, at := flaky(t, v.fails, v.code, "")
+		_, res, err := DoWithRetry(context.Background(), v.p, v.m, srv.URL, time.Second, strings.NewReader("body"))
+		if n := len(at()); n != v.n || v.ok != (err == nil) {
+			t.Errorf("%s %d: %d requests, error %v", v.m, v.code, n, err)
+		}
+		if err == nil {
+			// body is sent again on every attempt
+			if b, _ := ioutil.ReadAll(res); string(b) != "body" {
+				t.Errorf("%s %d: body %q", v.m, v.code, b)
+			}
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	p := Policy{Attempts: 2, MinWait: time.Millisecond, MaxWait: time.Millisecond}
+
+	for _, code := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		srv, at := flaky(t, 1, code, "1")
+		_, _, err := DoWithRetry(context.Background(), p, "GET", srv.URL, time.Second, nil)
+		if err != nil {
+			t.Fatalf("%d: %v", code, err)
+		}
+		if l := at(); len(l) != 2 || l[1].Sub(l[0]) < 900*time.Millisecond {
+			t.Errorf("%d: Retry-After is not honored %v", code, l)
+		}
+	}
+
+	// Retry-After beyond MaxTime stops retries at once
+	p.MaxTime = 100 * time.Millisecond
+	srv, at := flaky(t, 1, http.StatusTooManyRequests, "60")
+	start := time.Now()
+	_, _, err := DoWithRetry(context.Background(), p, "GET", srv.URL, time.Second, nil)
+	if err == nil || len(at()) != 1 || time.Since(start) > time.Second {
+		t.Errorf("%d requests in %v, error %v", len(at()), time.Since(start), err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	for _, v := range []struct {
+		s        string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"120", 120 * time.Second, 120 * time.Second},
+		{"0", 0, 0},
+		{"-5", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), 55 * time.Second, time.Minute},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+	} {
+		if d := parseRetryAfter(v.s); d < v.min || d > v.max {
+			t.Errorf("%q: %v", v.s, d)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+package mailcli
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// imapMsg is a message of imapStandIn
+type imapMsg struct {
+	uid  int
+	seen bool
+	del  bool
+	data string
+}
+
+// imapStandIn is an in-process IMAP server which knows just enough of
+// RFC 3501 for imapClient, folders are kept in memory
+type imapStandIn struct {
+	ln       net.Listener
+	move     bool // MOVE capability, COPY, STORE and EXPUNGE otherwise
+	validity int
+
+	mu      sync.Mutex
+	folders map[string][]*imapMsg
+	next    map[string]int
+	cmds    []string // commands without tags
+}
+
+var reQuoted = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+
+func newIMAPStandIn(t *testing.T, move bool, inbox ...*imapMsg) *imapStandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &imapStandIn{
+		ln:       ln,
+		move:     move,
+		validity: 42,
+		folders:  map[string][]*imapMsg{"INBOX": inbox},
+		next:     map[string]int{"INBOX": 100},
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+
+	return s
+}
+
+func (s *imapStandIn) addr(query string) string {
+	return "imap://user:pass@" + s.ln.Addr().String() + "/INBOX?" + query
+}
+
+// uids returns UIDs of messages of folder
+func (s *imapStandIn) uids(folder string) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var l []int
+	for _, m := range s.folders[folder] {
+		l = append(l, m.uid)
+	}
+	return l
+}
+
+func (s *imapStandIn) seen(folder string, uid int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range s.folders[folder] {
+		if m.uid == uid {
+			return m.seen
+		}
+	}
+	return false
+}
+
+// commands returns commands which start with prefix
+func (s *imapStandIn) commands(prefix string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var l []string
+	for _, v := range s.cmds {
+		if strings.HasPrefix(v, prefix) {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+func (s *imapStandIn) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	var (
+		r   = bufio.NewReader(conn)
+		w   = bufio.NewWriter(conn)
+		cur string
+	)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(w, format+"\r\n", args...)
+	}
+
+	reply("* OK stand-in ready")
+	_ = w.Flush()
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		v := strings.SplitN(line, " ", 2)
+		if len(v) != 2 {
+			reply("* BAD no command")
+			_ = w.Flush()
+			continue
+		}
+		tag, cmd := v[0], v[1]
+
+		s.mu.Lock()
+		s.cmds = append(s.cmds, cmd)
+		ok := s.handle(cmd, &cur, reply)
+		s.mu.Unlock()
+
+		if ok == "BYE" {
+			reply("* BYE")
+			reply("%s OK", tag)
+			_ = w.Flush()
+			return
+		}
+		reply("%s %s", tag, ok)
+		_ = w.Flush()
+	}
+}
+
+// handle runs command on selected folder cur, it returns tagged response
+func (s *imapStandIn) handle(cmd string, cur *string, reply func(string, ...interface{})) string {
+	var args []string
+	for _, m := range reQuoted.FindAllStringSubmatch(cmd, -1) {
+		args = append(args, strings.NewReplacer(`\\`, `\`, `\"`, `"`).Replace(m[1]))
+	}
+	f := strings.Fields(cmd)
+	name := strings.ToUpper(f[0])
+	if name == "UID" && len(f) > 1 {
+		name += " " + strings.ToUpper(f[1])
+	}
+
+	switch name {
+	case "LOGIN":
+		if len(args) != 2 || args[0] != "user" || args[1] != "pass" {
+			return "NO invalid credentials"
+		}
+		return "OK"
+	case "CAPABILITY":
+		caps := "IMAP4rev1 UIDPLUS"
+		if s.move {
+			caps += " MOVE"
+		}
+		reply("* CAPABILITY %s", caps)
+		return "OK"
+	case "LOGOUT":
+		return "BYE"
+	case "SELECT":
+		l, ok := s.folders[args[0]]
+		if !ok {
+			return "NO no such folder"
+		}
+		*cur = args[0]
+		reply("* %d EXISTS", len(l))
+		reply("* OK [UIDVALIDITY %d] UIDs valid", s.validity)
+		return "OK [READ-WRITE]"
+	case "CREATE":
+		if _, ok := s.folders[args[0]]; ok {
+			return "NO folder exists"
+		}
+		s.folders[args[0]] = nil
+		s.next[args[0]] = 1
+		return "OK"
+	}
+
+	m := s.find(*cur, f)
+	switch name {
+	case "UID SEARCH":
+		var l []string
+		for _, v := range s.folders[*cur] {
+			if !v.seen && !v.del {
+				l = append(l, strconv.Itoa(v.uid))
+			}
+		}
+		reply("* SEARCH %s", strings.Join(l, " "))
+		return "OK"
+	case "UID FETCH":
+		if m == nil {
+			return "OK"
+		}
+		var data, item string
+		switch {
+		case strings.Contains(cmd, "BODY.PEEK[HEADER]"):
+			data, item = m.data[:strings.Index(m.data, "\r\n\r\n")+4], "BODY[HEADER]"
+		case strings.Contains(cmd, "BODY.PEEK[]"):
+			data, item = m.data, "BODY[]"
+		default:
+			data, item = m.data, "BODY[]"
+			m.seen = true
+		}
+		reply("* 1 FETCH (UID %d RFC822.SIZE %d %s {%d}\r\n%s)", m.uid, len(m.data), item, len(data), data)
+		return "OK"
+	case "UID MOVE", "UID COPY":
+		if m == nil {
+			return "NO no such message"
+		}
+		dst := args[0]
+		if _, ok := s.folders[dst]; !ok {
+			return "NO [TRYCREATE] no such folder"
+		}
+		s.folders[dst] = append(s.folders[dst], &imapMsg{uid: s.next[dst], data: m.data})
+		s.next[dst]++
+		if name == "UID MOVE" {
+			s.remove(*cur, m)
+		}
+		return "OK"
+	case "UID STORE":
+		if m == nil {
+			return "NO no such message"
+		}
+		if strings.Contains(cmd, `\Seen`) {
+			m.seen = true
+		}
+		if strings.Contains(cmd, `\Deleted`) {
+			m.del = true
+		}
+		return "OK"
+	case "UID EXPUNGE", "EXPUNGE":
+		for _, v := range append([]*imapMsg(nil), s.folders[*cur]...) {
+			if v.del && (name == "EXPUNGE" || v == m) {
+				s.remove(*cur, v)
+			}
+		}
+		return "OK"
+	}
+
+	return "BAD unknown command"
+}
+
+// find returns message of folder by UID which is the third field of command
+func (s *imapStandIn) find(folder string, f []string) *imapMsg {
+	if len(f) < 3 {
+		return nil
+	}
+	uid, err := strconv.Atoi(f[2])
+	if err != nil {
+		return nil
+	}
+	for _, m := range s.folders[folder] {
+		if m.uid == uid {
+			return m
+		}
+	}
+	return nil
+}
+
+func (s *imapStandIn) remove(folder string, m *imapMsg) {
+	l := s.folders[folder][:0]
+	for _, v := range s.folders[folder] {
+		if v != m {
+			l = append(l, v)
+		}
+	}
+	s.folders[folder] = l
+}
+
+// testMail returns message from sender with attachment name
+func testMail(from, name, content string) string {
+	return "From: " + from + "\r\n" +
+		"Subject: price\r\n" +
+		"Date: Fri, 16 Oct 2026 10:00:00 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=XX\r\n" +
+		"\r\n" +
+		"--XX\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"price is attached\r\n" +
+		"--XX\r\n" +
+		"Content-Type: text/csv; name=\"" + name + "\"\r\n" +
+		"Content-Disposition: attachment; filename=\"" + name + "\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		base64.StdEncoding.EncodeToString([]byte(content)) + "\r\n" +
+		"--XX--\r\n"
+}
+
+const brokenMail = "From: a@good.com\r\nSubject: broken\r\n\r\nplain text\r\n"
+
+func testInbox() []*imapMsg {
+	return []*imapMsg{
+		{uid: 1, data: testMail("a@good.com", "p1.csv", "1;2;3")},
+		{uid: 2, data: testMail("a@good.com", "p2.csv", "4;5;6"), seen: true},
+		{uid: 3, data: brokenMail},
+		{uid: 4, data: testMail("x@bad.com", "p4.csv", "7;8;9")},
+	}
+}
+
+func TestIMAPFileChan(t *testing.T) {
+	s := newIMAPStandIn(t, true, testInbox()...)
+
+	var (
+		names, uids []string
+		broken      []string
+		content     string
+	)
+	for v := range NewIMAPFileChan(context.Background(), s.addr("from=*@good.com&failed=Failed"), nil, func(uid string) { broken = append(broken, uid) }) {
+		if v.Error != nil {
+			t.Fatal(v.Error)
+		}
+		b, err := ioutil.ReadAll(v.File)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, v.File.Name())
+		uids = append(uids, v.File.UID())
+		content = string(b)
+	}
+
+	if fmt.Sprint(names, uids) != "[p1.csv] [42.1]" || content != "1;2;3" {
+		t.Errorf("files %v, UIDs %v, content %q", names, uids, content)
+	}
+	if fmt.Sprint(broken) != "[42.3]" {
+		t.Errorf("broken %v, want [42.3]", broken)
+	}
+
+	// search of unseen messages, headers of filtered ones, then bodies by peek
+	if l := s.commands("UID SEARCH"); fmt.Sprint(l) != "[UID SEARCH UNSEEN]" {
+		t.Errorf("search %v", l)
+	}
+	if l := s.commands("UID FETCH 4"); fmt.Sprint(l) != "[UID FETCH 4 (RFC822.SIZE BODY.PEEK[HEADER])]" {
+		t.Errorf("fetch of filtered message %v", l)
+	}
+	if l := s.commands("UID FETCH 1"); len(l) != 2 || l[1] != "UID FETCH 1 (RFC822.SIZE BODY.PEEK[])" {
+		t.Errorf("fetch of message %v", l)
+	}
+
+	// nothing is changed until IMAPDone, e.g. in dry-run
+	for _, p := range []string{"UID MOVE", "UID COPY", "UID STORE", "CREATE"} {
+		if l := s.commands(p); len(l) != 0 {
+			t.Errorf("listing changed mailbox: %v", l)
+		}
+	}
+	if s.seen("INBOX", 1) {
+		t.Errorf("message 1 is seen after listing")
+	}
+}
+
+func TestIMAPMailChanMaxSize(t *testing.T) {
+	s := newIMAPStandIn(t, true, testInbox()...)
+
+	var uids, broken []string
+	for v := range NewIMAPMailChan(context.Background(), s.addr("maxsize=100"), func(uid string) { broken = append(broken, uid) }) {
+		if v.Error != nil {
+			t.Fatal(v.Error)
+		}
+		uids = append(uids, v.UID)
+	}
+
+	// only the broken message is small enough to be fetched
+	if len(uids) != 0 || fmt.Sprint(broken) != "[42.3]" {
+		t.Errorf("UIDs %v, broken %v, want only broken 42.3", uids, broken)
+	}
+	if l := s.commands("UID FETCH 1"); fmt.Sprint(l) != "[UID FETCH 1 (RFC822.SIZE BODY.PEEK[HEADER])]" {
+		t.Errorf("fetch of large message %v", l)
+	}
+}
+
+func TestIMAPDone(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		move      bool
+		query     string
+		inbox     []int // UIDs left in INBOX
+		processed []int // UIDs in Done
+		failed    []int // UIDs in Failed
+		seen      bool  // message 1 is seen
+	}{
+		{"move", true, "processed=Done&failed=Failed", []int{2, 4}, []int{1}, []int{1}, false},
+		{"copy", false, "processed=Done&failed=Failed", []int{2, 4}, []int{1}, []int{1}, false},
+		{"seen", true, "failed=Failed", []int{1, 2, 4}, nil, []int{1}, true},
+		{"no failed folder", true, "processed=Done", []int{2, 3, 4}, []int{1}, nil, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newIMAPStandIn(t, tc.move, testInbox()...)
+
+			err := IMAPDone(context.Background(), s.addr(tc.query), []string{"42.1"}, []string{"42.3"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, v := range []struct {
+				folder string
+				want   []int
+			}{
+				{"INBOX", tc.inbox},
+				{"Done", tc.processed},
+				{"Failed", tc.failed},
+			} {
+				if got := s.uids(v.folder); fmt.Sprint(got) != fmt.Sprint(v.want) {
+					t.Errorf("%s: UIDs %v, want %v", v.folder, got, v.want)
+				}
+			}
+			if s.seen("INBOX", 1) != tc.seen {
+				t.Errorf("message 1 seen %v, want %v", !tc.seen, tc.seen)
+			}
+
+			// folder is created once
+			if l := s.commands("CREATE"); len(l) > 2 {
+				t.Errorf("create %v", l)
+			}
+		})
+	}
+}
+
+func TestIMAPDoneNothing(t *testing.T) {
+	s := newIMAPStandIn(t, true, testInbox()...)
+
+	// failed messages are left unseen without failed folder, no login at all
+	err := IMAPDone(context.Background(), s.addr("processed=Done"), nil, []string{"42.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := s.commands("LOGIN"); len(l) != 0 {
+		t.Errorf("login %v", l)
+	}
+}
+
+func TestIMAPDoneValidity(t *testing.T) {
+	s := newIMAPStandIn(t, true, testInbox()...)
+
+	err := IMAPDone(context.Background(), s.addr("processed=Done"), []string{"41.1"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "UIDVALIDITY") {
+		t.Fatalf("error %v, want changed UIDVALIDITY", err)
+	}
+	if l := s.uids("INBOX"); len(l) != 4 {
+		t.Errorf("INBOX %v, want all messages", l)
+	}
+}
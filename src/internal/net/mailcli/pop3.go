@@ -1,31 +1,43 @@
 package mailcli
 
 import (
-	"bytes"
+	"bufio"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"io"
 	"mime"
 	"mime/multipart"
+	"net"
 	"net/mail"
 	"net/textproto"
 	"net/url"
 	"strings"
+	"time"
+
+	"internal/logs"
+	"internal/net/tlsconf"
 
 	pop3 "github.com/bytbox/go-pop3"
 )
 
+var logger = logs.For("mailcli")
+
 // Filer is representation for attach in mail message from POP3
 type Filer interface {
 	io.Reader
 	Name() string
 	Subj() string
+	Time() time.Time
+	UID() string // UID of message, empty if server has no UIDL
 }
 
 type file struct {
 	r    io.Reader
 	name string
 	subj string
+	time time.Time
+	uid  string
 }
 
 func (f file) Name() string {
@@ -36,6 +48,14 @@ func (f file) Subj() string {
 	return f.subj
 }
 
+func (f file) Time() time.Time {
+	return f.time
+}
+
+func (f file) UID() string {
+	return f.uid
+}
+
 func (f file) Read(p []byte) (int, error) {
 	return f.r.Read(p)
 }
@@ -46,6 +66,10 @@ func newPOP3(addr string) (*pop3.Client, error) {
 		return nil, err
 	}
 
+	return dialPOP3(u)
+}
+
+func dialPOP3(u *url.URL) (*pop3.Client, error) {
 	if u.User == nil {
 		return nil, fmt.Errorf("pop3: user must be defined")
 	}
@@ -53,38 +77,111 @@ func newPOP3(addr string) (*pop3.Client, error) {
 	user := u.User.Username()
 	pass, _ := u.User.Password()
 
-	c, err := pop3.Dial(u.Host)
+	t, err := tlsconf.Parse(u, "pop3")
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := t.Dial(tlsconf.HostPort(u, t, "110", "995"))
+	if err != nil {
+		return nil, err
+	}
+
+	if t.StartTLS {
+		conn, err = stls(conn, t)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c, err := pop3.NewClient(conn)
 	if err != nil {
+		_ = conn.Close()
 		return nil, err
 	}
 
 	err = c.Auth(user, pass)
 	if err != nil {
+		_ = c.Quit()
 		return nil, err
 	}
 
 	return c, nil
 }
 
-// NewMailChan allows to work with messages from POP3 server in a pipe style
-func NewMailChan(addr string, cleanup bool) <-chan struct {
+// stls upgrades plain connection to TLS (RFC 2595), the connection is
+// closed on error
+func stls(conn net.Conn, t tlsconf.Options) (net.Conn, error) {
+	var (
+		r    = bufio.NewReader(conn)
+		line string
+		err  error
+	)
+
+	_ = conn.SetDeadline(time.Now().Add(tlsconf.Timeout))
+	for _, cmd := range []string{"", "STLS\r\n"} {
+		_, err = io.WriteString(conn, cmd)
+		if err == nil {
+			line, err = r.ReadString('\n')
+		}
+		if err == nil && !strings.HasPrefix(line, "+OK") {
+			err = fmt.Errorf("pop3: STLS: %s", strings.TrimSpace(line))
+		}
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	s, err := t.Client(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	// pop3.NewClient reads greeting, which is read already
+	return greeted{Conn: s, r: io.MultiReader(strings.NewReader("+OK\r\n"), s)}, nil
+}
+
+// greeted is a connection which replays greeting
+type greeted struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c greeted) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// NewMailChan allows to work with messages from POP3 server in a pipe style,
+// the pipe is closed between messages when ctx is done. Messages with UIDs
+// kept in dir (see Done) are skipped, so are messages larger than maxsize
+// and messages whose headers (TOP) do not match from and subject of addr,
+// UIDs of the latter are reported to skipped (if not nil) to keep them by Done
+func NewMailChan(ctx context.Context, addr, dir string, cleanup bool, skipped func(uid string)) <-chan struct {
 	Mail  io.Reader
+	UID   string
 	Error error
 } {
 	var (
 		pipe = make(chan struct {
 			Mail  io.Reader
+			UID   string
 			Error error
 		})
-		makeResult = func(r io.Reader, err error) struct {
+		makeResult = func(r io.Reader, uid string, err error) struct {
 			Mail  io.Reader
+			UID   string
 			Error error
 		} {
 			return struct {
 				Mail  io.Reader
+				UID   string
 				Error error
 			}{
 				r,
+				uid,
 				err,
 			}
 		}
@@ -93,47 +190,124 @@ func NewMailChan(addr string, cleanup bool) <-chan struct {
 		defer func() { close(pipe) }()
 
 		var (
-			c   *pop3.Client
-			l   []int
-			m   string
-			err error
+			u     *url.URL
+			o     options
+			c     *pop3.Client
+			l     []int
+			sizes []int
+			uids  map[int]string
+			seen  map[string]bool
+			h     mail.Header
+			m     string
+			err   error
 		)
 
-		c, err = newPOP3(addr)
+		u, err = url.Parse(addr)
+		if err != nil {
+			goto fail
+		}
+
+		o, err = parseOptions(u)
+		if err != nil {
+			goto fail
+		}
+		if o.leave && dir == "" {
+			err = fmt.Errorf("pop3: leave requires directory for UIDs")
+			goto fail
+		}
+
+		seen, err = openUIDs(dir, u).load()
+		if err != nil {
+			goto fail
+		}
+
+		c, err = dialPOP3(u)
 		if err != nil {
 			goto fail
 		}
 		defer func() { _ = c.Quit() }()
 
-		l, _, err = c.ListAll()
+		l, sizes, err = c.ListAll()
 		if err != nil {
 			goto fail
 		}
+		logger.DebugContext(ctx, "list", "proto", "pop3", "messages", len(l))
+
+		// without UIDL every message is new
+		uids, err = uidl(c)
+		if err != nil {
+			if o.leave {
+				err = fmt.Errorf("pop3: leave requires UIDL: %v", err)
+				goto fail
+			}
+			uids = map[int]string{}
+		}
 
 		for i := range l {
+			if err = ctx.Err(); err != nil {
+				goto fail
+			}
+
+			uid := uids[l[i]]
+			if uid != "" && seen[uid] {
+				continue
+			}
+
+			if o.maxSize > 0 && sizes[i] > o.maxSize {
+				logger.InfoContext(ctx, "skip message", "proto", "pop3", "msg", l[i], "uid", uid, "bytes", sizes[i])
+				skip(skipped, uid)
+				continue
+			}
+
+			if o.filtered() {
+				h, err = top(c, l[i])
+				if err != nil {
+					goto fail
+				}
+				if !o.headerOK(h) {
+					skip(skipped, uid)
+					continue
+				}
+			}
+
 			m, err = c.Retr(l[i])
 			if err != nil {
 				goto fail
 			}
+			logger.DebugContext(ctx, "retr", "proto", "pop3", "msg", l[i], "uid", uid, "bytes", len(m))
 
-			if cleanup {
+			if cleanup && !o.leave {
 				err = c.Dele(l[i])
 				if err != nil {
 					goto fail
 				}
 			}
 
-			pipe <- makeResult(strings.NewReader(m), nil)
+			select {
+			case pipe <- makeResult(strings.NewReader(m), uid, nil):
+			case <-ctx.Done():
+				return
+			}
 		}
 
 		return // success
 	fail:
-		pipe <- makeResult(nil, err)
+		select {
+		case pipe <- makeResult(nil, "", err):
+		case <-ctx.Done():
+		}
 	}()
 
 	return pipe
 }
 
+// skip reports UID of message which is inspected but not passed on
+func skip(skipped func(uid string), uid string) {
+	if skipped != nil && uid != "" {
+		skipped(uid)
+	}
+}
+
 func skipFile(h textproto.MIMEHeader, name string, nameOK func(string) bool) bool {
 	badBase := !foundBase64Attach(h)
 	badName := nameOK != nil && !nameOK(name)
@@ -146,8 +320,26 @@ func copyFile(dst io.Writer, src io.ReadCloser) error {
 	return err
 }
 
-// NewFileChan allows to work with files (attachments) from POP3 server in a pipe style
-func NewFileChan(addr string, nameOK func(string) bool, cleanup bool) <-chan struct {
+// NewFileChan allows to work with files (attachments) from POP3 server in a pipe style,
+// the pipe is closed between messages when ctx is done, see NewMailChan for dir
+// and skipped, a message without matching attachments is skipped as well.
+// A file is decoded while it is read, so it must be read to the end before
+// the next one is received. The message itself is kept in memory (go-pop3
+// returns it as a string), use maxsize to limit it
+func NewFileChan(ctx context.Context, addr, dir string, nameOK func(string) bool, cleanup bool, skipped func(uid string)) <-chan struct {
+	File  Filer
+	Error error
+} {
+	return fileChan(ctx, NewMailChan(ctx, addr, dir, cleanup, skipped), nameOK, skipped)
+}
+
+// fileChan extracts attachments from messages of vCh, UIDs of messages
+// without matching attachments are reported to skipped (if not nil)
+func fileChan(ctx context.Context, vCh <-chan struct {
+	Mail  io.Reader
+	UID   string
+	Error error
+}, nameOK func(string) bool, skipped func(uid string)) <-chan struct {
 	File  Filer
 	Error error
 } {
@@ -176,11 +368,11 @@ func NewFileChan(addr string, nameOK func(string) bool, cleanup bool) <-chan str
 			m   *mail.Message
 			s   string
 			err error
-			vCh = NewMailChan(addr, cleanup)
 		)
 
 		for v := range vCh {
 			if v.Error != nil {
+				err = v.Error
 				goto fail
 			}
 
@@ -197,7 +389,7 @@ func NewFileChan(addr string, nameOK func(string) bool, cleanup bool) <-chan str
 			var (
 				r = multipart.NewReader(m.Body, s) // multipart reader
 				p *multipart.Part
-				b *bytes.Buffer
+				n int // files of message
 			)
 			for {
 				p, err = r.NextPart()
@@ -214,32 +406,169 @@ func NewFileChan(addr string, nameOK func(string) bool, cleanup bool) <-chan str
 					continue
 				}
 
-				b = new(bytes.Buffer)
-				err = copyFile(b, p)
-				if err != nil {
-					goto fail
-				}
+				n++
+				pr, pw := io.Pipe()
 
-				pipe <- makeResult(
+				select {
+				case pipe <- makeResult(
 					file{
-						r:    b,
+						r:    pr,
 						name: s,
 						subj: m.Header.Get("Subject"),
+						time: findDate(m.Header),
+						uid:  v.UID,
 					},
 					nil,
-				)
+				):
+				case <-ctx.Done():
+					return
+				}
+
+				// the file is decoded while it is read, an error is
+				// returned to the reader and ends the pipe
+				err = copyFile(pw, p)
+				_ = pw.CloseWithError(err)
+				if err != nil {
+					return
+				}
 			}
 
+			if n == 0 {
+				skip(skipped, v.UID)
+			}
 		}
 
 		return // success
 	fail:
-		pipe <- makeResult(nil, err)
+		select {
+		case pipe <- makeResult(nil, err):
+		case <-ctx.Done():
+		}
 	}()
 
 	return pipe
 }
 
+// Done is called after downstream success, it remembers UIDs of handled
+// messages in dir and deletes the messages unless addr has leave=1, UIDs of
+// skipped messages (see NewFileChan) are remembered, but the messages are kept
+func Done(ctx context.Context, addr, dir string, uid, skipped []string) error {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return err
+	}
+
+	o, err := parseOptions(u)
+	if err != nil {
+		return err
+	}
+
+	c, err := dialPOP3(u)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.Quit() }()
+
+	uids, err := uidl(c)
+	if err != nil {
+		return err
+	}
+
+	if !o.leave {
+		done := make(map[string]bool, len(uid))
+		for i := range uid {
+			done[uid[i]] = true
+		}
+		for n, v := range uids {
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+			if !done[v] {
+				continue
+			}
+			err = c.Dele(n)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	seen := append(append([]string(nil), uid...), skipped...)
+	return openUIDs(dir, u).save(uids, seen...)
+}
+
+// Delete deletes messages which contain attachments with names,
+// it stops between messages when ctx is done
+func Delete(ctx context.Context, addr string, name ...string) error {
+	c, err := newPOP3(addr)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.Quit() }()
+
+	l, _, err := c.ListAll()
+	if err != nil {
+		return err
+	}
+
+	for i := range l {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		m, err := c.Retr(l[i])
+		if err != nil {
+			return err
+		}
+
+		if !foundFileName(m, name...) {
+			continue
+		}
+
+		err = c.Dele(l[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func foundFileName(msg string, name ...string) bool {
+	m, err := mail.ReadMessage(strings.NewReader(msg))
+	if err != nil {
+		return false
+	}
+
+	s, err := findBoundary(m.Header)
+	if err != nil {
+		return false
+	}
+
+	r := multipart.NewReader(m.Body, s)
+	for {
+		p, err := r.NextPart()
+		if err != nil {
+			return false
+		}
+
+		s, _ = findFileName(p.Header)
+		for i := range name {
+			if s != "" && s == name[i] {
+				return true
+			}
+		}
+	}
+}
+
+func findDate(h mail.Header) time.Time {
+	t, err := h.Date()
+	if err != nil {
+		return time.Now()
+	}
+	return t
+}
+
 func findBoundary(h mail.Header) (string, error) {
 	t, p, err := mime.ParseMediaType(h.Get("Content-Type"))
 	if err != nil {
@@ -0,0 +1,288 @@
+package mailcli
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"internal/net/tlsconf/tlstest"
+)
+
+// pop3Msg is a message of pop3StandIn
+type pop3Msg struct {
+	uid  string
+	del  bool
+	data string
+}
+
+// pop3StandIn is an in-process POP3 server which knows just enough of
+// RFC 1939 for NewMailChan and Done, the mailbox is kept in memory
+type pop3StandIn struct {
+	ln  net.Listener
+	tls *tls.Config // STLS is supported if not nil
+
+	mu   sync.Mutex
+	msgs []*pop3Msg
+	cmds []string
+}
+
+func newPOP3StandIn(t *testing.T, msgs ...*pop3Msg) *pop3StandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &pop3StandIn{ln: ln, msgs: msgs}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+
+	return s
+}
+
+func (s *pop3StandIn) addr(query string) string {
+	return "pop3://user:pass@" + s.ln.Addr().String() + "?" + query
+}
+
+// commands returns commands which start with prefix
+func (s *pop3StandIn) commands(prefix string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var l []string
+	for _, v := range s.cmds {
+		if strings.HasPrefix(v, prefix) {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+// left returns UIDs of messages which are not deleted
+func (s *pop3StandIn) left() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var l []string
+	for _, m := range s.msgs {
+		if !m.del {
+			l = append(l, m.uid)
+		}
+	}
+	return l
+}
+
+func (s *pop3StandIn) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	var (
+		r    = bufio.NewReader(conn)
+		w    = bufio.NewWriter(conn)
+		dele []*pop3Msg // deleted on QUIT
+	)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(w, format+"\r\n", args...)
+	}
+
+	reply("+OK stand-in ready")
+	_ = w.Flush()
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		f := strings.Fields(cmd)
+		if len(f) == 0 {
+			continue
+		}
+
+		s.mu.Lock()
+		s.cmds = append(s.cmds, cmd)
+		var m *pop3Msg
+		if len(f) > 1 {
+			if n, err := strconv.Atoi(f[1]); err == nil && n > 0 && n <= len(s.msgs) {
+				m = s.msgs[n-1]
+			}
+		}
+
+		switch strings.ToUpper(f[0]) {
+		case "USER", "PASS", "NOOP":
+			reply("+OK")
+		case "STLS":
+			if s.tls == nil {
+				reply("-ERR not supported")
+				break
+			}
+			reply("+OK begin TLS")
+			_ = w.Flush()
+			conn = tls.Server(conn, s.tls)
+			r, w = bufio.NewReader(conn), bufio.NewWriter(conn)
+		case "LIST", "UIDL":
+			reply("+OK")
+			for i, v := range s.msgs {
+				if f[0] == "LIST" {
+					reply("%d %d", i+1, len(v.data))
+				} else {
+					reply("%d %s", i+1, v.uid)
+				}
+			}
+			reply(".")
+		case "TOP", "RETR":
+			if m == nil {
+				reply("-ERR no such message")
+				break
+			}
+			data := m.data
+			if f[0] == "TOP" {
+				data = data[:strings.Index(data, "\r\n\r\n")+2]
+			}
+			reply("+OK")
+			for _, l := range strings.SplitAfter(strings.TrimSuffix(data, "\r\n"), "\r\n") {
+				l = strings.TrimSuffix(l, "\r\n")
+				if strings.HasPrefix(l, ".") {
+					l = "." + l
+				}
+				reply("%s", l)
+			}
+			reply(".")
+		case "DELE":
+			if m == nil {
+				reply("-ERR no such message")
+				break
+			}
+			dele = append(dele, m)
+			reply("+OK")
+		case "QUIT":
+			for _, v := range dele {
+				v.del = true
+			}
+			reply("+OK")
+			s.mu.Unlock()
+			_ = w.Flush()
+			return
+		default:
+			reply("-ERR unknown command")
+		}
+		s.mu.Unlock()
+		_ = w.Flush()
+	}
+}
+
+func testMailbox() []*pop3Msg {
+	return []*pop3Msg{
+		{uid: "u1", data: testMail("a@good.com", "p1.csv", "1;2;3")},
+		{uid: "u2", data: testMail("x@bad.com", "p2.csv", "4;5;6")},
+		{uid: "u3", data: testMail("a@good.com", "readme.txt", "no price")},
+	}
+}
+
+// readFiles reads files of mailbox and returns names, UIDs of files and
+// UIDs of skipped messages
+func readFiles(t *testing.T, addr, dir string) (names, uids, skipped []string) {
+	nameOK := func(name string) bool { return strings.HasSuffix(name, ".csv") }
+	add := func(uid string) { skipped = append(skipped, uid) }
+	for v := range NewFileChan(context.Background(), addr, dir, nameOK, false, add) {
+		if v.Error != nil {
+			t.Fatal(v.Error)
+		}
+		if _, err := ioutil.ReadAll(v.File); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, v.File.Name())
+		uids = append(uids, v.File.UID())
+	}
+	return names, uids, skipped
+}
+
+func TestPOP3Leave(t *testing.T) {
+	s := newPOP3StandIn(t, testMailbox()...)
+	dir := t.TempDir()
+	addr := s.addr("leave=1&from=*@good.com")
+
+	names, uids, skipped := readFiles(t, addr, dir)
+	if strings.Join(names, ",") != "p1.csv" || strings.Join(uids, ",") != "u1" {
+		t.Fatalf("names %v, UIDs %v", names, uids)
+	}
+	if strings.Join(skipped, ",") != "u2,u3" {
+		t.Fatalf("skipped %v", skipped)
+	}
+
+	err := Done(context.Background(), addr, dir, uids, skipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.commands("DELE")) != 0 || len(s.left()) != 3 {
+		t.Errorf("deleted with leave: %v", s.commands("DELE"))
+	}
+
+	// every message is seen, none is inspected again
+	n := len(s.commands("TOP")) + len(s.commands("RETR"))
+	names, _, skipped = readFiles(t, addr, dir)
+	if len(names) != 0 || len(skipped) != 0 {
+		t.Errorf("names %v, skipped %v", names, skipped)
+	}
+	if len(s.commands("TOP"))+len(s.commands("RETR")) != n {
+		t.Errorf("messages are inspected again: %v", s.commands(""))
+	}
+}
+
+func TestPOP3Done(t *testing.T) {
+	s := newPOP3StandIn(t, testMailbox()...)
+	dir := t.TempDir()
+	addr := s.addr("from=*@good.com")
+
+	_, uids, skipped := readFiles(t, addr, dir)
+	err := Done(context.Background(), addr, dir, uids, skipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// skipped messages are kept on server
+	if l := s.left(); strings.Join(l, ",") != "u2,u3" {
+		t.Errorf("left %v", l)
+	}
+}
+
+func TestPOP3STLS(t *testing.T) {
+	s := newPOP3StandIn(t, testMailbox()...)
+	cfg, ca := tlstest.Server(t)
+	s.tls = cfg
+
+	names, _, _ := readFiles(t, s.addr("starttls=1&ca="+ca), "")
+	if strings.Join(names, ",") != "p1.csv,p2.csv" {
+		t.Errorf("names %v", names)
+	}
+	if l := s.commands("USER"); len(s.commands("STLS")) != 1 || len(l) != 1 {
+		t.Errorf("commands %v", s.commands(""))
+	}
+
+	// certificate is verified
+	for v := range NewFileChan(context.Background(), s.addr("starttls=1"), "", nil, false, nil) {
+		if v.Error == nil || !strings.Contains(v.Error.Error(), "certificate") {
+			t.Errorf("error %v", v.Error)
+		}
+	}
+
+	s.tls = nil
+	for v := range NewFileChan(context.Background(), s.addr("starttls=1&ca="+ca), "", nil, false, nil) {
+		if v.Error == nil || !strings.Contains(v.Error.Error(), "pop3: STLS: -ERR not supported") {
+			t.Errorf("error %v", v.Error)
+		}
+	}
+}
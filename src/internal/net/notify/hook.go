@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"internal/net/httpcli"
+)
+
+func init() {
+	Register("hook+http", newHook)
+	Register("hook+https", newHook)
+}
+
+// defaultHook is JSON body of webhook unless tmpl is defined
+const defaultHook = `{
+	"command": {{json .Command}},
+	"subject": {{json .Subject}},
+	"text": {{json .Text}},
+	"error": {{json .Error}},
+	"time": {{json .Time}}
+}`
+
+var hookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"base64": func(b []byte) string {
+		return base64.StdEncoding.EncodeToString(b)
+	},
+}
+
+// hook posts JSON made of Message by text/template to URL, e.g.
+// hook+https://chat.example.com/hooks/xyz?tmpl=/etc/m15/chat.tmpl, tmpl
+// (file) is removed from query, the rest of URL is posted as is
+type hook struct {
+	url  string
+	name string
+	tmpl *template.Template
+	opt  Options
+}
+
+func newHook(u *url.URL, o Options) (Notifier, error) {
+	var (
+		q    = u.Query()
+		text = defaultHook
+	)
+
+	if v := q.Get("tmpl"); v != "" {
+		b, err := ioutil.ReadFile(v)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+		q.Del("tmpl")
+	}
+
+	t, err := template.New("hook").Funcs(hookFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	v := *u
+	v.Scheme = strings.TrimPrefix(strings.ToLower(u.Scheme), "hook+")
+	v.RawQuery = q.Encode()
+
+	// path of webhook is often a secret
+	name := (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()
+
+	return &hook{url: v.String(), name: name, tmpl: t, opt: o}, nil
+}
+
+func (h *hook) String() string {
+	return h.name
+}
+
+// Notify posts message, the body must be valid JSON
+func (h *hook) Notify(ctx context.Context, m Message) error {
+	b := new(bytes.Buffer)
+	err := h.tmpl.Execute(b, m)
+	if err != nil {
+		return err
+	}
+
+	if !json.Valid(b.Bytes()) {
+		return fmt.Errorf("template makes invalid JSON")
+	}
+
+	_, _, err = httpcli.DoWithRetry(ctx, h.opt.Retry.Marked(), "POST", h.url, h.opt.Timeout, b,
+		"Content-Type: application/json; charset=utf-8")
+	return err
+}
@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"context"
+	"net/url"
+
+	"internal/net/mailcli"
+)
+
+func init() {
+	Register("smtp", newSMTP)
+	Register("smtps", newSMTP)
+	Register("mailgun", newMailgun)
+	Register("mail", newMailgun) // -mgn
+}
+
+type smtpNotifier struct {
+	addr string
+	name string
+	from string
+	to   []string
+}
+
+func newSMTP(u *url.URL, o Options) (Notifier, error) {
+	from, to, err := mailOptions(u, o)
+	if err != nil {
+		return nil, err
+	}
+	return &smtpNotifier{addr: u.String(), name: redact(u), from: from, to: to}, nil
+}
+
+func (n *smtpNotifier) String() string {
+	return n.name
+}
+
+// Notify sends mail, see mailcli.SendSMTP
+func (n *smtpNotifier) Notify(ctx context.Context, m Message) error {
+	return mailcli.SendSMTP(ctx, n.addr, n.from, m.Subject, m.Text, m.HTML, attachments(m), n.to...)
+}
+
+type mailgunNotifier struct {
+	addr string
+	name string
+	from string
+	to   []string
+}
+
+func newMailgun(u *url.URL, o Options) (Notifier, error) {
+	from, to, err := mailOptions(u, o)
+	if err != nil {
+		return nil, err
+	}
+	return &mailgunNotifier{addr: u.String(), name: redact(u), from: from, to: to}, nil
+}
+
+func (n *mailgunNotifier) String() string {
+	return n.name
+}
+
+// Notify sends mail via https://www.mailgun.com/
+func (n *mailgunNotifier) Notify(ctx context.Context, m Message) error {
+	return mailcli.SendMail(ctx, n.addr, n.from, m.Subject, m.Text, m.HTML, attachments(m), n.to...)
+}
+
+func attachments(m Message) []mailcli.Attachment {
+	l := make([]mailcli.Attachment, len(m.Attachments))
+	for i := range m.Attachments {
+		l[i] = mailcli.Attachment{Name: m.Attachments[i].Name, Data: m.Attachments[i].Data}
+	}
+	return l
+}
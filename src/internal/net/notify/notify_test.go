@@ -0,0 +1,372 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"internal/net/tlsconf/tlstest"
+)
+
+var testMessage = Message{
+	Command: "foz",
+	Subject: "ERROR [foz]",
+	Text:    "run failed",
+	HTML:    "<p>run failed</p>",
+	Error:   "bad file",
+	Time:    time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC),
+	Attachments: []Attachment{
+		{Name: "rejects.csv", Data: []byte("1;2;3")},
+	},
+}
+
+var testOptions = Options{
+	From:    "m15@example.com",
+	To:      []string{"ops@example.com"},
+	Timeout: 5 * time.Second,
+}
+
+func TestHook(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		path string
+		body map[string]interface{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		path = r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer srv.Close()
+
+	addr := "hook+" + srv.URL + "/hooks/s3cret"
+	n, err := New(addr, testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(n.String(), "s3cret") {
+		t.Errorf("name %s has path of webhook", n)
+	}
+
+	err = n.Notify(context.Background(), testMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if path != "/hooks/s3cret" {
+		t.Errorf("path %s", path)
+	}
+	for k, v := range map[string]string{
+		"command": "foz",
+		"subject": "ERROR [foz]",
+		"text":    "run failed",
+		"error":   "bad file",
+		"time":    "2026-10-16T10:00:00Z",
+	} {
+		if body[k] != v {
+			t.Errorf("%s: %v, want %s", k, body[k], v)
+		}
+	}
+}
+
+func TestHookFailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such hook", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	n, err := New("hook+"+srv.URL+"/hooks/x", testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = n.Notify(context.Background(), testMessage); err == nil {
+		t.Fatal("error is expected")
+	}
+}
+
+func TestMailgun(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		path   string
+		user   string
+		pass   string
+		fields = make(map[string]string)
+		files  = make(map[string]string)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		path = r.URL.Path
+		user, pass, _ = r.BasicAuth()
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for k, v := range r.MultipartForm.Value {
+			fields[k] = strings.Join(v, ",")
+		}
+		for _, v := range r.MultipartForm.File["attachment"] {
+			f, _ := v.Open()
+			b, _ := ioutil.ReadAll(f)
+			files[v.Filename] = string(b)
+		}
+		_, _ = w.Write([]byte(`{"id": "<1@box.example.com>", "message": "Queued. Thank you."}`))
+	}))
+	defer srv.Close()
+
+	n, err := New("mailgun://key-1@box.example.com?api="+srv.URL+"/v3", testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(n.String(), "key-1") {
+		t.Errorf("name %s has key", n)
+	}
+
+	err = n.Notify(context.Background(), testMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if path != "/v3/box.example.com/messages" || user != "api" || pass != "key-1" {
+		t.Errorf("path %s, user %s, pass %s", path, user, pass)
+	}
+	for k, v := range map[string]string{
+		"from":    "m15@example.com",
+		"to":      "ops@example.com",
+		"subject": "ERROR [foz]",
+		"text":    "run failed",
+		"html":    "<p>run failed</p>",
+	} {
+		if fields[k] != v {
+			t.Errorf("%s: %q, want %q", k, fields[k], v)
+		}
+	}
+	if files["rejects.csv"] != "1;2;3" {
+		t.Errorf("attachments %v", files)
+	}
+}
+
+func TestMailgunContext(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	n, err := New("mailgun://key-1@box.example.com?api="+srv.URL+"/v3", testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	t0 := time.Now()
+	err = n.Notify(ctx, testMessage)
+	if err == nil {
+		t.Fatal("error is expected")
+	}
+	if d := time.Since(t0); d > 5*time.Second {
+		t.Errorf("notify took %v after ctx is done", d)
+	}
+}
+
+// smtpStandIn is a minimal SMTP server which keeps the last mail
+type smtpStandIn struct {
+	ln  net.Listener
+	tls *tls.Config // STARTTLS is supported if not nil
+
+	mu     sync.Mutex
+	secure bool // the last mail is sent over TLS
+	auth   string
+	from   string
+	to     []string
+	data   string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &smtpStandIn{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+
+	return s
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	var (
+		r      = bufio.NewReader(conn)
+		reply  = func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+		secure bool
+	)
+
+	reply("220 stand-in ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+
+		s.mu.Lock()
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-stand-in")
+			reply("250-8BITMIME")
+			if s.tls != nil && !secure {
+				reply("250-STARTTLS")
+			}
+			reply("250 AUTH PLAIN")
+		case cmd == "STARTTLS":
+			reply("220 2.0.0 ready to start TLS")
+			conn = tls.Server(conn, s.tls)
+			r = bufio.NewReader(conn)
+			secure = true
+		case strings.HasPrefix(cmd, "AUTH PLAIN"):
+			s.auth = line[len("AUTH PLAIN "):]
+			reply("235 2.7.0 Authentication successful")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			s.from = line[len("MAIL FROM:"):]
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.to = append(s.to, line[len("RCPT TO:"):])
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 end with .")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					s.mu.Unlock()
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				b.WriteString(l)
+			}
+			s.data = b.String()
+			s.secure = secure
+			reply("250 OK queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			s.mu.Unlock()
+			return
+		default:
+			reply("250 OK")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	s := newSMTPStandIn(t)
+
+	n, err := New("smtp://user:pass@"+s.ln.Addr().String()+"?to=a@example.com,b@example.com", testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(n.String(), "pass") {
+		t.Errorf("name %s has password", n)
+	}
+
+	err = n.Notify(context.Background(), testMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.auth == "" {
+		t.Errorf("no auth")
+	}
+	if !strings.HasPrefix(s.from, "<m15@example.com>") || strings.Join(s.to, ",") != "<a@example.com>,<b@example.com>" {
+		t.Errorf("from %s, to %v", s.from, s.to)
+	}
+	for _, v := range []string{
+		"Subject: ERROR [foz]",
+		"multipart/alternative",
+		"text/html",
+		"filename=rejects.csv",
+		"MTsyOzM=",
+	} {
+		if !strings.Contains(s.data, v) {
+			t.Errorf("mail has no %q:\n%s", v, s.data)
+		}
+	}
+}
+
+func TestSMTPStartTLS(t *testing.T) {
+	s := newSMTPStandIn(t)
+	cfg, ca := tlstest.Server(t)
+	s.tls = cfg
+
+	n, err := New("smtp://user:pass@"+s.ln.Addr().String()+"?to=a@example.com&starttls=1&ca="+ca, testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Notify(context.Background(), testMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	secure := s.secure
+	s.mu.Unlock()
+	if !secure {
+		t.Error("mail is sent without TLS")
+	}
+
+	// certificate is verified
+	n, err = New("smtp://user:pass@"+s.ln.Addr().String()+"?to=a@example.com&starttls=1", testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Notify(context.Background(), testMessage)
+	if err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("error %v", err)
+	}
+}
+
+func TestSMTPNoRecipients(t *testing.T) {
+	s := newSMTPStandIn(t)
+
+	o := testOptions
+	o.To = nil
+	if _, err := New("smtp://"+s.ln.Addr().String(), o); err == nil {
+		t.Fatal("error is expected")
+	}
+}
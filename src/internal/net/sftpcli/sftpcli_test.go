@@ -0,0 +1,365 @@
+package sftpcli
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"internal/store/spool"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// standInPass is always accepted, it is used to prepare and check files
+const standInPass = "stand-in"
+
+// sftpStandIn is an in-process SSH server with SFTP subsystem which keeps
+// files in memory, see sftp.InMemHandler
+type sftpStandIn struct {
+	ln      net.Listener
+	hostKey ssh.Signer
+	fs      sftp.Handlers
+	pass    string
+	key     ssh.PublicKey // authorized key of client, nil if password only
+}
+
+func newSFTPStandIn(t *testing.T, pass string, key ssh.PublicKey) *sftpStandIn {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &sftpStandIn{ln: ln, hostKey: hostKey, fs: sftp.InMemHandler(), pass: pass, key: key}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+
+	return s
+}
+
+func (s *sftpStandIn) serve(conn net.Conn) {
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if string(pass) == standInPass || s.pass != "" && string(pass) == s.pass {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if s.key != nil && bytes.Equal(key.Marshal(), s.key.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	cfg.AddHostKey(s.hostKey)
+
+	sc, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	defer func() { _ = sc.Close() }()
+	go ssh.DiscardRequests(reqs)
+
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			_ = nc.Reject(ssh.UnknownChannelType, "session only")
+			continue
+		}
+		ch, in, err := nc.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for r := range in {
+				ok := r.Type == "subsystem" && string(r.Payload[4:]) == "sftp"
+				_ = r.Reply(ok, nil)
+				if ok {
+					go func() {
+						_ = sftp.NewRequestServer(ch, s.fs).Serve()
+						_ = ch.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// knownHosts writes known_hosts file with key for address of server
+func (s *sftpStandIn) knownHosts(t *testing.T, key ssh.PublicKey) string {
+	name := filepath.Join(t.TempDir(), "known_hosts")
+	err := ioutil.WriteFile(name, []byte(knownhosts.Line([]string{s.ln.Addr().String()}, key)+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+// put creates file on server, parent directories are created as well
+func (s *sftpStandIn) put(t *testing.T, name, data string) {
+	c := s.client(t)
+	defer func() { _ = c.Close() }()
+
+	err := c.MkdirAll(filepath.Dir(name))
+	if err == nil {
+		var f *sftp.File
+		f, err = c.Create(name)
+		if err == nil {
+			_, err = f.Write([]byte(data))
+			_ = f.Close()
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// get returns content of file on server, empty if there is no file
+func (s *sftpStandIn) get(t *testing.T, name string) string {
+	c := s.client(t)
+	defer func() { _ = c.Close() }()
+
+	f, err := c.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f.Close() }()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// client connects to server directly, bypassing sftpcli
+func (s *sftpStandIn) client(t *testing.T) *sftp.Client {
+	sc, err := ssh.Dial("tcp", s.ln.Addr().String(), &ssh.ClientConfig{
+		User:            "test",
+		Auth:            []ssh.AuthMethod{ssh.Password(standInPass)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := sftp.NewClient(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func readAll(t *testing.T, addr string, nameOK func(string) bool, cleanup bool) (map[string]string, error) {
+	files := make(map[string]string)
+	var err error
+	for v := range NewFileChan(context.Background(), addr, spool.Spool{Dir: t.TempDir()}, nameOK, cleanup) {
+		if v.Error != nil {
+			err = v.Error
+			continue
+		}
+		b, rerr := ioutil.ReadAll(v.File)
+		_ = v.File.Close()
+		if rerr != nil {
+			t.Fatal(rerr)
+		}
+		files[v.File.Name()] = string(b)
+	}
+	return files, err
+}
+
+func TestFileChanPassword(t *testing.T) {
+	s := newSFTPStandIn(t, "s3cret", nil)
+	s.put(t, "/drop/a.csv", "1;2;3")
+	s.put(t, "/drop/b.txt", "readme")
+	s.put(t, "/drop/empty.csv", "")
+	s.put(t, "/drop/sub/c.csv", "nested")
+
+	addr := "sftp://user:s3cret@" + s.ln.Addr().String() + "/drop?knownhosts=" + s.knownHosts(t, s.hostKey.PublicKey())
+	files, err := readAll(t, addr, func(name string) bool { return strings.HasSuffix(name, ".csv") }, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files["a.csv"] != "1;2;3" {
+		t.Errorf("files %v", files)
+	}
+	if s.get(t, "/drop/a.csv") == "" {
+		t.Error("a.csv is deleted without cleanup")
+	}
+}
+
+func TestFileChanBadPassword(t *testing.T) {
+	s := newSFTPStandIn(t, "s3cret", nil)
+
+	addr := "sftp://user:guess@" + s.ln.Addr().String() + "/drop?knownhosts=" + s.knownHosts(t, s.hostKey.PublicKey())
+	_, err := readAll(t, addr, nil, false)
+	if err == nil || !strings.Contains(err.Error(), "unable to authenticate") {
+		t.Fatalf("error %v", err)
+	}
+}
+
+func TestFileChanKnownHosts(t *testing.T) {
+	s := newSFTPStandIn(t, "s3cret", nil)
+	s.put(t, "/drop/a.csv", "1;2;3")
+
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := ssh.NewSignerFromKey(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty")
+	err = ioutil.WriteFile(empty, nil, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []struct {
+		knownHosts string
+		err        string
+	}{
+		{s.knownHosts(t, k.PublicKey()), "key mismatch"},
+		{empty, "key is unknown"},
+		{filepath.Join(t.TempDir(), "missing", "known_hosts"), "sftp: known hosts"},
+	} {
+		addr := "sftp://user:s3cret@" + s.ln.Addr().String() + "/drop?knownhosts=" + v.knownHosts
+		files, err := readAll(t, addr, nil, false)
+		if err == nil || !strings.Contains(err.Error(), v.err) || len(files) != 0 {
+			t.Errorf("%s: error %v, want %s", v.knownHosts, err, v.err)
+		}
+	}
+}
+
+func TestFileChanKey(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", der, []byte("phrase"), x509.PEMCipherAES128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := filepath.Join(t.TempDir(), "id_ecdsa")
+	err = ioutil.WriteFile(key, pem.EncodeToMemory(block), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ssh.NewPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newSFTPStandIn(t, "", pub)
+	s.put(t, "/drop/a.csv", "1;2;3")
+	s.put(t, "/drop/done/a.csv", "old")
+	kh := s.knownHosts(t, s.hostKey.PublicKey())
+
+	// password of address is passphrase of key
+	addr := "sftp://user:phrase@" + s.ln.Addr().String() + "/drop?archive=done&key=" + key + "&knownhosts=" + kh
+	files, err := readAll(t, addr, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files["a.csv"] != "1;2;3" {
+		t.Errorf("files %v", files)
+	}
+	if s.get(t, "/drop/a.csv") != "" || s.get(t, "/drop/done/a.csv") != "1;2;3" {
+		t.Error("a.csv is not moved to archive")
+	}
+
+	_, err = readAll(t, "sftp://user:wrong@"+s.ln.Addr().String()+"/drop?key="+key+"&knownhosts="+kh, nil, false)
+	if err == nil || !strings.Contains(err.Error(), "sftp: "+key) {
+		t.Errorf("error %v", err)
+	}
+}
+
+// renamer is FileCmder without posix-rename extension, so Rename fails if
+// target exists
+type renamer struct {
+	sftp.FileCmder
+}
+
+func TestDeleteArchive(t *testing.T) {
+	for _, posix := range []bool{true, false} {
+		s := newSFTPStandIn(t, "s3cret", nil)
+		if !posix {
+			s.fs.FileCmd = renamer{s.fs.FileCmd}
+		}
+		s.put(t, "/drop/a.csv", "new")
+		s.put(t, "/drop/b.csv", "b")
+		s.put(t, "/drop/done/a.csv", "old")
+		kh := s.knownHosts(t, s.hostKey.PublicKey())
+
+		addr := "sftp://user:s3cret@" + s.ln.Addr().String() + "/drop?archive=done&knownhosts=" + kh
+		err := Delete(context.Background(), addr, "a.csv")
+		if err != nil {
+			t.Fatalf("posix %v: %v", posix, err)
+		}
+		if s.get(t, "/drop/a.csv") != "" || s.get(t, "/drop/done/a.csv") != "new" || s.get(t, "/drop/b.csv") != "b" {
+			t.Errorf("posix %v: a.csv is not replaced in archive", posix)
+		}
+
+		// without archive files are deleted
+		addr = "sftp://user:s3cret@" + s.ln.Addr().String() + "/drop?knownhosts=" + kh
+		err = Delete(context.Background(), addr, "b.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.get(t, "/drop/b.csv") != "" {
+			t.Errorf("posix %v: b.csv is not deleted", posix)
+		}
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	u, err := url.Parse("sftp://user@host")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	home, _ := os.UserHomeDir()
+	o := parseOptions(u)
+	if o.dir != "." || o.knownHosts != filepath.Join(home, ".ssh", "known_hosts") || o.key != "" || o.archive != "" {
+		t.Errorf("options %+v", o)
+	}
+}
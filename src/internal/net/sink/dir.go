@@ -0,0 +1,74 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	Register("dir", newDir)
+}
+
+type dir struct {
+	path string
+}
+
+func newDir(u *url.URL, _ Options) (Sinker, error) {
+	return NewDir(filepath.FromSlash(u.Host + u.Path))
+}
+
+// NewDir returns Sinker which writes payloads as files into directory
+func NewDir(path string) (Sinker, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sink: dir must be defined")
+	}
+
+	err := os.MkdirAll(path, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dir{path: path}, nil
+}
+
+func (d *dir) String() string {
+	return "dir " + d.path
+}
+
+// Push writes payload to file <name>.json.gz
+func (d *dir) Push(_ context.Context, r io.Reader, name string) error {
+	f, err := os.Create(filepath.Join(d.path, FileName(name)))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// FileName makes file-safe name with .json.gz extension from payload name
+func FileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		return r
+	}, name)
+
+	if !strings.HasSuffix(strings.ToLower(name), ".gz") {
+		name += ".json.gz"
+	}
+
+	return name
+}
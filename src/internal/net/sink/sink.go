@@ -0,0 +1,83 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"internal/net/httpcli"
+)
+
+// Skynet API versions
+const (
+	V1 = iota + 1
+	V2
+)
+
+// Sinker is a destination for gzip(json) payloads
+type Sinker interface {
+	// Push sends payload, name is a short file-safe payload name (e.g. "ave_123")
+	Push(ctx context.Context, r io.Reader, name string) error
+	// String returns destination without credentials for logging
+	String() string
+}
+
+// Pinger is implemented by sinks which can check availability before run
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Options are defaults taken from command flags
+type Options struct {
+	Key     string
+	Tag     string
+	API     int // default API version of command
+	Timeout time.Duration
+	Retry   httpcli.Policy
+}
+
+// Opener makes Sinker for address
+type Opener func(u *url.URL, o Options) (Sinker, error)
+
+var (
+	mu      sync.RWMutex
+	openers = make(map[string]Opener)
+)
+
+// Register makes Opener available by URL scheme
+func Register(scheme string, fn Opener) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if fn == nil {
+		panic("sink: opener is nil")
+	}
+	openers[strings.ToLower(scheme)] = fn
+}
+
+// New returns Sinker chosen by scheme of addr:
+// http(s)://host (skynet with API of command), v1+http(s)://host, v2+http(s)://host,
+// dir:///path/to/dir, stdout: (or -)
+func New(addr string, o Options) (Sinker, error) {
+	if addr == "-" {
+		addr = "stdout:"
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mu.RLock()
+	fn, ok := openers[strings.ToLower(u.Scheme)]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("sink: unknown scheme '%s'", u.Scheme)
+	}
+
+	return fn(u, o)
+}
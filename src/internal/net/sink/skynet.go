@@ -0,0 +1,87 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"internal/net/httpcli"
+	"internal/version"
+)
+
+func init() {
+	for _, v := range []string{"http", "https"} {
+		Register(v, newSkynet)
+		Register("v1+"+v, newSkynet)
+		Register("v2+"+v, newSkynet)
+	}
+}
+
+type skynet struct {
+	srv string
+	api int
+	opt Options
+}
+
+func newSkynet(u *url.URL, o Options) (Sinker, error) {
+	s := &skynet{api: o.API, opt: o}
+
+	scheme := u.Scheme
+	if i := strings.Index(scheme, "+"); i > 0 {
+		switch scheme[:i] {
+		case "v1":
+			s.api = V1
+		case "v2":
+			s.api = V2
+		}
+		scheme = scheme[i+1:]
+	}
+
+	if s.api != V1 && s.api != V2 {
+		return nil, fmt.Errorf("sink: unknown skynet API version %d", s.api)
+	}
+
+	v := *u
+	v.Scheme = scheme
+	s.srv = strings.TrimSuffix(v.String(), "/")
+
+	return s, nil
+}
+
+func (s *skynet) makeURL(path string) string {
+	return fmt.Sprintf("%s%s", s.srv, path)
+}
+
+func (s *skynet) String() string {
+	return fmt.Sprintf("skynet v%d %s", s.api, s.srv)
+}
+
+// Ping checks skynet availability
+func (s *skynet) Ping(ctx context.Context) error {
+	_, _, err := httpcli.DoWithRetry(ctx, s.opt.Retry, "GET", s.makeURL("/ping"), s.opt.Timeout, nil)
+	return err
+}
+
+// Push sends gzip(json) to /data/add, the request is marked as retryable
+// because skynet replaces data of shop
+func (s *skynet) Push(ctx context.Context, r io.Reader, _ string) error {
+	var url string
+	var hdr []string
+	hdr = append(hdr, "Content-Encoding: application/x-gzip")
+	hdr = append(hdr, "User-Agent: "+fmt.Sprintf("%s %s", version.AppName(), version.WithBuildInfo()))
+	switch s.api {
+	case V1:
+		url = s.makeURL("/data/add?key=") + s.opt.Key
+		hdr = append(hdr, "Content-Type: application/json; charset=utf-8; hashtag="+s.opt.Tag)
+	case V2:
+		url = s.makeURL("/data/add")
+		hdr = append(hdr, "Content-Type: application/json; charset=utf-8")
+		hdr = append(hdr, "X-Morion-Skynet-Key: "+s.opt.Key)
+		hdr = append(hdr, "X-Morion-Skynet-Tag: "+s.opt.Tag)
+	}
+
+	_, _, err := httpcli.DoWithRetry(ctx, s.opt.Retry.Marked(), "POST", url, s.opt.Timeout, r, hdr...)
+	return err
+}
@@ -0,0 +1,42 @@
+package sink
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"sync"
+)
+
+func init() {
+	Register("stdout", newStdout)
+}
+
+type stdout struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newStdout(_ *url.URL, _ Options) (Sinker, error) {
+	return &stdout{w: os.Stdout}, nil
+}
+
+func (s *stdout) String() string {
+	return "stdout"
+}
+
+// Push writes ungzipped payload to stdout
+func (s *stdout) Push(_ context.Context, r io.Reader, _ string) error {
+	z, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = z.Close() }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = io.Copy(s.w, z)
+	return err
+}
@@ -0,0 +1,143 @@
+package source
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"internal/store/spool"
+)
+
+func init() {
+	Register("file", newFile)
+}
+
+type fileSource struct {
+	dir    string
+	only   string // not empty if path points to file
+	origin Origin
+	opt    Options
+}
+
+func newFile(u *url.URL, o Options) (Sourcer, error) {
+	p := filepath.FromSlash(u.Host + u.Path)
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &fileSource{dir: p, origin: makeOrigin(u), opt: o}
+	if !fi.IsDir() {
+		s.dir, s.only = filepath.Split(p)
+	}
+
+	return s, nil
+}
+
+func (s *fileSource) Files(ctx context.Context, nameOK func(string) bool, cleanup bool) <-chan struct {
+	File  Filer
+	Error error
+} {
+	pipe := make(chan struct {
+		File  Filer
+		Error error
+	})
+	go func() {
+		defer func() { close(pipe) }()
+
+		var (
+			l   []os.FileInfo
+			f   *spool.File
+			err error
+		)
+
+		l, err = ioutil.ReadDir(s.dir)
+		if err != nil {
+			goto fail
+		}
+
+		for _, v := range l {
+			if skipFile(v, s.only, nameOK) {
+				continue
+			}
+
+			if err = ctx.Err(); err != nil {
+				goto fail
+			}
+
+			f, err = s.open(v.Name(), cleanup)
+			if err != nil {
+				goto fail
+			}
+
+			if !send(ctx, pipe,
+				file{
+					Reader: f,
+					name:   v.Name(),
+					time:   v.ModTime(),
+					origin: s.origin,
+				},
+				nil,
+			) {
+				_ = f.Close()
+				return
+			}
+		}
+
+		return // success
+	fail:
+		send(ctx, pipe, nil, err)
+	}()
+
+	return pipe
+}
+
+// open opens file in place, it is copied to spool if it is deleted on the fly
+func (s *fileSource) open(name string, cleanup bool) (*spool.File, error) {
+	p := filepath.Join(s.dir, name)
+	if !cleanup {
+		return s.opt.Spool.Open(p)
+	}
+
+	r, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.opt.Spool.Copy(r)
+	_ = r.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Remove(p)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (s *fileSource) Delete(ctx context.Context, name ...string) error {
+	for i := range name {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := os.Remove(filepath.Join(s.dir, filepath.Base(name[i])))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func skipFile(fi os.FileInfo, only string, nameOK func(string) bool) bool {
+	badType := !fi.Mode().IsRegular()
+	badSize := fi.Size() <= 0
+	badOnly := only != "" && fi.Name() != only
+	badName := nameOK != nil && !nameOK(fi.Name())
+	return badType || badSize || badOnly || badName
+}
@@ -0,0 +1,59 @@
+package source
+
+import (
+	"context"
+	"net/url"
+
+	"internal/net/ftpcli"
+)
+
+func init() {
+	Register("ftp", newFTP)
+	Register("ftps", newFTP)
+}
+
+type ftpSource struct {
+	addr   string
+	origin Origin
+	opt    Options
+}
+
+func newFTP(u *url.URL, o Options) (Sourcer, error) {
+	return &ftpSource{addr: u.String(), origin: makeOrigin(u), opt: o}, nil
+}
+
+func (s *ftpSource) Files(ctx context.Context, nameOK func(string) bool, cleanup bool) <-chan struct {
+	File  Filer
+	Error error
+} {
+	pipe := make(chan struct {
+		File  Filer
+		Error error
+	})
+	go func() {
+		defer func() { close(pipe) }()
+		for v := range ftpcli.NewFileChan(ctx, s.addr, s.opt.Spool, nameOK, cleanup) {
+			if v.Error != nil {
+				send(ctx, pipe, nil, v.Error)
+				continue
+			}
+			if !send(ctx, pipe,
+				file{
+					Reader: v.File,
+					name:   v.File.Name(),
+					time:   v.File.Time(),
+					origin: s.origin,
+				},
+				nil,
+			) {
+				_ = v.File.Close()
+			}
+		}
+	}()
+
+	return pipe
+}
+
+func (s *ftpSource) Delete(ctx context.Context, name ...string) error {
+	return ftpcli.Delete(ctx, s.addr, name...)
+}
@@ -0,0 +1,83 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"internal/net/httpcli"
+)
+
+func init() {
+	Register("http", newHTTP)
+	Register("https", newHTTP)
+}
+
+type httpSource struct {
+	addr   string
+	origin Origin
+	opt    Options
+}
+
+func newHTTP(u *url.URL, o Options) (Sourcer, error) {
+	return &httpSource{addr: u.String(), origin: makeOrigin(u), opt: o}, nil
+}
+
+// Files downloads the only file by URL, cleanup is ignored
+func (s *httpSource) Files(ctx context.Context, nameOK func(string) bool, _ bool) <-chan struct {
+	File  Filer
+	Error error
+} {
+	pipe := make(chan struct {
+		File  Filer
+		Error error
+	})
+	go func() {
+		defer func() { close(pipe) }()
+
+		name := path.Base(s.origin.Path)
+		if nameOK != nil && !nameOK(name) {
+			return
+		}
+
+		h, r, err := httpcli.OpenWithRetry(ctx, s.opt.Retry, "GET", s.addr, s.opt.Timeout, nil)
+		if err != nil {
+			send(ctx, pipe, nil, err)
+			return
+		}
+
+		f, err := s.opt.Spool.Copy(r)
+		_ = r.Close()
+		if err != nil {
+			send(ctx, pipe, nil, fmt.Errorf("%s: %v", name, err))
+			return
+		}
+
+		t, err := http.ParseTime(h.Get("Last-Modified"))
+		if err != nil {
+			t = time.Now()
+		}
+
+		if !send(ctx, pipe,
+			file{
+				Reader: f,
+				name:   name,
+				time:   t,
+				origin: s.origin,
+			},
+			nil,
+		) {
+			_ = f.Close()
+		}
+	}()
+
+	return pipe
+}
+
+// Delete does nothing because HTTP source is read-only
+func (s *httpSource) Delete(_ context.Context, name ...string) error {
+	return nil
+}
@@ -0,0 +1,149 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sync"
+
+	"internal/net/mailcli"
+)
+
+func init() {
+	Register("imap", newIMAP)
+	Register("imaps", newIMAP)
+}
+
+type imapSource struct {
+	addr   string
+	origin Origin
+	opt    Options
+
+	mu     sync.Mutex
+	uids   map[string][]string // file name -> UIDs of messages, see Delete
+	done   map[string]bool     // UIDs of messages moved by Delete or Fail
+	broken []string            // UIDs of messages which can not be parsed
+}
+
+func newIMAP(u *url.URL, o Options) (Sourcer, error) {
+	return &imapSource{addr: u.String(), origin: makeOrigin(u), opt: o, uids: make(map[string][]string), done: make(map[string]bool)}, nil
+}
+
+func (s *imapSource) addBroken(uid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.broken = append(s.broken, uid)
+}
+
+// Files returns attachments of unseen messages, messages are never removed
+// while reading, so cleanup is ignored
+func (s *imapSource) Files(ctx context.Context, nameOK func(string) bool, cleanup bool) <-chan struct {
+	File  Filer
+	Error error
+} {
+	pipe := make(chan struct {
+		File  Filer
+		Error error
+	})
+	go func() {
+		defer func() { close(pipe) }()
+		for v := range mailcli.NewIMAPFileChan(ctx, s.addr, nameOK, s.addBroken) {
+			if v.Error != nil {
+				send(ctx, pipe, nil, v.Error)
+				continue
+			}
+			f, err := s.opt.Spool.Copy(v.File)
+			if err != nil {
+				// the rest of file is decoded while it is read
+				_, _ = io.Copy(ioutil.Discard, v.File)
+				send(ctx, pipe, nil, fmt.Errorf("%s: %v", v.File.Name(), err))
+				continue
+			}
+			s.mu.Lock()
+			s.uids[v.File.Name()] = append(s.uids[v.File.Name()], v.File.UID())
+			s.mu.Unlock()
+			o := s.origin
+			o.Subj = v.File.Subj()
+			if !send(ctx, pipe,
+				file{
+					Reader: f,
+					name:   v.File.Name(),
+					time:   v.File.Time(),
+					origin: o,
+				},
+				nil,
+			) {
+				_ = f.Close()
+			}
+		}
+	}()
+
+	return pipe
+}
+
+// Delete moves messages which contain attachments with names to processed
+// folder or flags them \Seen, broken messages are moved to failed folder
+func (s *imapSource) Delete(ctx context.Context, name ...string) error {
+	var (
+		uids []string
+		seen = make(map[string]bool)
+	)
+	s.mu.Lock()
+	for i := range name {
+		for _, v := range s.uids[name[i]] {
+			if !seen[v] && !s.done[v] {
+				seen[v] = true
+				uids = append(uids, v)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	return s.finish(ctx, uids)
+}
+
+// Fail moves messages which were read but not handled to failed folder,
+// they are left unseen if there is no failed folder
+func (s *imapSource) Fail(ctx context.Context) error {
+	var (
+		failed []string
+		seen   = make(map[string]bool)
+	)
+	s.mu.Lock()
+	for _, l := range s.uids {
+		for _, v := range l {
+			if !seen[v] && !s.done[v] {
+				seen[v] = true
+				failed = append(failed, v)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	return s.finish(ctx, nil, failed...)
+}
+
+// finish moves handled and failed messages along with broken ones
+func (s *imapSource) finish(ctx context.Context, done []string, failed ...string) error {
+	s.mu.Lock()
+	broken := s.broken
+	s.mu.Unlock()
+
+	err := mailcli.IMAPDone(ctx, s.addr, done, append(failed, broken...))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.broken = s.broken[len(broken):]
+	for _, l := range [][]string{done, failed} {
+		for _, v := range l {
+			s.done[v] = true
+		}
+	}
+	s.mu.Unlock()
+
+	return nil
+}
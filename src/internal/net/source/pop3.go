@@ -0,0 +1,115 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sync"
+
+	"internal/net/mailcli"
+)
+
+func init() {
+	Register("pop3", newPOP3)
+	Register("pop3s", newPOP3)
+}
+
+type pop3Source struct {
+	addr   string
+	origin Origin
+	opt    Options
+
+	mu      sync.Mutex
+	uids    map[string][]string // file name -> UIDs of messages, see Delete
+	skipped []string            // UIDs of messages without files, see Delete
+}
+
+func newPOP3(u *url.URL, o Options) (Sourcer, error) {
+	return &pop3Source{addr: u.String(), origin: makeOrigin(u), opt: o, uids: make(map[string][]string)}, nil
+}
+
+func (s *pop3Source) Files(ctx context.Context, nameOK func(string) bool, cleanup bool) <-chan struct {
+	File  Filer
+	Error error
+} {
+	pipe := make(chan struct {
+		File  Filer
+		Error error
+	})
+	go func() {
+		defer func() { close(pipe) }()
+		for v := range mailcli.NewFileChan(ctx, s.addr, s.opt.UIDL, nameOK, cleanup, s.addSkipped) {
+			if v.Error != nil {
+				send(ctx, pipe, nil, v.Error)
+				continue
+			}
+			f, err := s.opt.Spool.Copy(v.File)
+			if err != nil {
+				// the rest of file is decoded while it is read
+				_, _ = io.Copy(ioutil.Discard, v.File)
+				send(ctx, pipe, nil, fmt.Errorf("%s: %v", v.File.Name(), err))
+				continue
+			}
+			if v.File.UID() != "" {
+				s.mu.Lock()
+				s.uids[v.File.Name()] = append(s.uids[v.File.Name()], v.File.UID())
+				s.mu.Unlock()
+			}
+			o := s.origin
+			o.Subj = v.File.Subj()
+			if !send(ctx, pipe,
+				file{
+					Reader: f,
+					name:   v.File.Name(),
+					time:   v.File.Time(),
+					origin: o,
+				},
+				nil,
+			) {
+				_ = f.Close()
+			}
+		}
+	}()
+
+	return pipe
+}
+
+func (s *pop3Source) addSkipped(uid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.skipped = append(s.skipped, uid)
+}
+
+// Delete deletes messages which contain attachments with names, messages
+// are left on server with leave=1 and remembered by UID to skip them later,
+// so are messages without files, which are never deleted
+func (s *pop3Source) Delete(ctx context.Context, name ...string) error {
+	var uids, rest []string
+	s.mu.Lock()
+	skipped := s.skipped
+	s.skipped = nil
+	for i := range name {
+		if l, ok := s.uids[name[i]]; ok {
+			uids = append(uids, l...)
+		} else {
+			rest = append(rest, name[i])
+		}
+	}
+	s.mu.Unlock()
+
+	if len(uids) > 0 || len(skipped) > 0 && s.opt.UIDL != "" {
+		err := mailcli.Done(ctx, s.addr, s.opt.UIDL, uids, skipped)
+		if err != nil {
+			return err
+		}
+	}
+
+	// server without UIDL, messages are found by attachment names
+	if len(rest) > 0 {
+		return mailcli.Delete(ctx, s.addr, rest...)
+	}
+
+	return nil
+}
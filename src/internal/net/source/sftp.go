@@ -0,0 +1,59 @@
+package source
+
+import (
+	"context"
+	"net/url"
+
+	"internal/net/sftpcli"
+)
+
+func init() {
+	Register("sftp", newSFTP)
+}
+
+type sftpSource struct {
+	addr   string
+	origin Origin
+	opt    Options
+}
+
+func newSFTP(u *url.URL, o Options) (Sourcer, error) {
+	return &sftpSource{addr: u.String(), origin: makeOrigin(u), opt: o}, nil
+}
+
+func (s *sftpSource) Files(ctx context.Context, nameOK func(string) bool, cleanup bool) <-chan struct {
+	File  Filer
+	Error error
+} {
+	pipe := make(chan struct {
+		File  Filer
+		Error error
+	})
+	go func() {
+		defer func() { close(pipe) }()
+		for v := range sftpcli.NewFileChan(ctx, s.addr, s.opt.Spool, nameOK, cleanup) {
+			if v.Error != nil {
+				send(ctx, pipe, nil, v.Error)
+				continue
+			}
+			if !send(ctx, pipe,
+				file{
+					Reader: v.File,
+					name:   v.File.Name(),
+					time:   v.File.Time(),
+					origin: s.origin,
+				},
+				nil,
+			) {
+				_ = v.File.Close()
+			}
+		}
+	}()
+
+	return pipe
+}
+
+// Delete deletes files or moves them to archive directory of address
+func (s *sftpSource) Delete(ctx context.Context, name ...string) error {
+	return sftpcli.Delete(ctx, s.addr, name...)
+}
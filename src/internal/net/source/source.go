@@ -0,0 +1,152 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"internal/net/httpcli"
+	"internal/store/spool"
+)
+
+// Filer is representation for file from any source, content is kept on disk
+// (see spool) and can be read more than once, Close frees it
+type Filer interface {
+	spool.Reader
+	Name() string
+	Time() time.Time
+	Origin() Origin
+}
+
+// Origin is metadata about where file came from
+type Origin struct {
+	Scheme string `json:"scheme,omitempty"`
+	Host   string `json:"host,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Subj   string `json:"subj,omitempty"` // mail subject
+}
+
+// String returns origin as URL without credentials
+func (o Origin) String() string {
+	return (&url.URL{Scheme: o.Scheme, Host: o.Host, Path: o.Path}).String()
+}
+
+// Sourcer is a transport which provides files
+type Sourcer interface {
+	// Files allows to work with files in a pipe style, cleanup deletes files
+	// on the fly after reading, the pipe is closed when ctx is done
+	Files(ctx context.Context, nameOK func(string) bool, cleanup bool) <-chan struct {
+		File  Filer
+		Error error
+	}
+	// Delete deletes files by names after successful processing
+	Delete(ctx context.Context, name ...string) error
+}
+
+// Failer is implemented by Sourcer which puts aside files of failed run,
+// e.g. IMAP moves messages to failed folder
+type Failer interface {
+	// Fail puts aside files which were read but not deleted, it is not
+	// called if run fails because of delivery (e.g. network errors)
+	Fail(ctx context.Context) error
+}
+
+// Options are transport settings taken from command flags
+type Options struct {
+	Timeout time.Duration
+	Retry   httpcli.Policy
+	Spool   spool.Spool
+	UIDL    string // directory for UIDs of handled POP3 messages
+}
+
+// Opener makes Sourcer for address
+type Opener func(u *url.URL, o Options) (Sourcer, error)
+
+var (
+	mu      sync.RWMutex
+	openers = make(map[string]Opener)
+)
+
+// Register makes Opener available by URL scheme
+func Register(scheme string, fn Opener) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if fn == nil {
+		panic("source: opener is nil")
+	}
+	openers[strings.ToLower(scheme)] = fn
+}
+
+// New returns Sourcer chosen by scheme of addr (ftp(s)://, sftp://, pop3(s)://, imap(s)://, http(s)://, file://)
+func New(addr string, o Options) (Sourcer, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mu.RLock()
+	fn, ok := openers[strings.ToLower(u.Scheme)]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("source: unknown scheme '%s'", u.Scheme)
+	}
+
+	return fn(u, o)
+}
+
+type file struct {
+	spool.Reader
+	name   string
+	time   time.Time
+	origin Origin
+}
+
+func (f file) Name() string {
+	return f.name
+}
+
+func (f file) Time() time.Time {
+	return f.time
+}
+
+func (f file) Origin() Origin {
+	return f.origin
+}
+
+func makeOrigin(u *url.URL) Origin {
+	return Origin{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+		Path:   u.Path,
+	}
+}
+
+// send sends result to pipe unless ctx is done
+func send(ctx context.Context, pipe chan<- struct {
+	File  Filer
+	Error error
+}, f Filer, err error) bool {
+	select {
+	case pipe <- makeResult(f, err):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func makeResult(f Filer, err error) struct {
+	File  Filer
+	Error error
+} {
+	return struct {
+		File  Filer
+		Error error
+	}{
+		f,
+		err,
+	}
+}
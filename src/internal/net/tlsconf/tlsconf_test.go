@@ -0,0 +1,41 @@
+package tlsconf
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, v := range []struct {
+		addr string
+		opt  Options
+		port string
+		err  string
+	}{
+		{"pop3://u@mail.example.com", Options{ServerName: "mail.example.com"}, "110", ""},
+		{"pop3s://u@mail.example.com", Options{Implicit: true, ServerName: "mail.example.com"}, "995", ""},
+		{"pop3://u@10.0.0.1:1110?starttls=1&ca=/etc/ca.pem&servername=mail", Options{StartTLS: true, CA: "/etc/ca.pem", ServerName: "mail"}, "1110", ""},
+		{"pop3s://u@mail.example.com?starttls=1", Options{}, "", "starttls is not allowed with pop3s://"},
+		{"pop3://u@mail.example.com?starttls=maybe", Options{}, "", "pop3: starttls"},
+	} {
+		u, err := url.Parse(v.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		o, err := Parse(u, "pop3")
+		if v.err != "" {
+			if err == nil || !strings.Contains(err.Error(), v.err) {
+				t.Errorf("%s: error %v, want %s", v.addr, err, v.err)
+			}
+			continue
+		}
+		if err != nil || o != v.opt {
+			t.Errorf("%s: %+v, %v", v.addr, o, err)
+		}
+		if p := HostPort(u, o, "110", "995"); !strings.HasSuffix(p, ":"+v.port) {
+			t.Errorf("%s: host %s", v.addr, p)
+		}
+	}
+}
@@ -1,10 +1,9 @@
 package run
 
 import (
-	"bytes"
-	"compress/gzip"
-	"encoding/json"
+	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -12,7 +11,8 @@ import (
 	"internal/archive/ziputil"
 	"internal/encoding/csvutil"
 	"internal/encoding/txtutil"
-	"internal/net/ftpcli"
+	"internal/model"
+	"internal/net/source"
 )
 
 const (
@@ -32,77 +32,67 @@ var (
 	walkWay = []string{fileApt, fileTov, fileOst} // strong order files
 )
 
-// Data structs
-
-type shop struct {
-	ID   string `json:"id,omitempty"`
-	Name string `json:"name,omitempty"`
-	Head string `json:"head,omitempty"`
-	Addr string `json:"addr,omitempty"`
-	Code string `json:"code,omitempty"`
-}
-
-type drug struct {
-	ID   string `json:"id,omitempty"`
-	Name string `json:"name,omitempty"`
-}
-
-type prop struct {
-	ID    string  `json:"id,omitempty"`
-	Name  string  `json:"name,omitempty"`
-	Quant float64 `json:"quant,omitempty"`
-	Price float64 `json:"price,omitempty"`
-}
-
-type price struct {
-	Meta shop   `json:"meta,omitempty"`
-	Data []prop `json:"data,omitempty"`
-}
-
 // Command
 
 type cmdAve struct {
 	cmdBase
 
-	mapFile map[string]ftpcli.Filer
-	mapShop map[string]shop
-	mapDrug map[string]drug
-	mapProp map[string][]prop
+	src     source.Sourcer
+	mapFile map[string]source.Filer
+	mapShop map[string]model.Shop
+	mapDrug map[string]model.Product
+	mapProp map[string][]model.Stock
 }
 
 func NewCmdAve() *cmdAve {
 	cmd := &cmdAve{
-		mapFile: make(map[string]ftpcli.Filer, capFile),
-		mapShop: make(map[string]shop, capShop),
-		mapDrug: make(map[string]drug, capDrug),
-		mapProp: make(map[string][]prop, capProp),
+		mapFile: make(map[string]source.Filer, capFile),
+		mapShop: make(map[string]model.Shop, capShop),
+		mapDrug: make(map[string]model.Product, capDrug),
+		mapProp: make(map[string][]model.Stock, capProp),
 	}
 	cmd.mustInitBase(cmd, "ave", "download, transform and send to skynet zip(csv) files from ftp")
+	cmd.wire = model.Price
 	return cmd
 }
 
-func (c *cmdAve) exec() error {
-	err := c.downloadZIPs()
+func (c *cmdAve) exec(ctx context.Context) error {
+	err := c.downloadZIPs(ctx)
+	if err != nil {
+		return err
+	}
+
+	done, err := c.allProcessed(filesOf(c.mapFile)...)
 	if err != nil {
 		return err
 	}
+	if done {
+		c.log.Info("nothing new", "stage", stagePull)
+		return c.deleteZIPs(ctx)
+	}
 
-	err = c.transformCSVs()
+	err = c.transformCSVs(ctx)
 	if err != nil {
 		return err
 	}
 
-	err = c.uploadGzipJSONs()
+	err = c.uploadGzipJSONs(ctx)
 	if err != nil {
 		return err
 	}
 
-	return c.deleteZIPs()
+	return c.deleteZIPs(ctx)
 }
 
-func (c *cmdAve) downloadZIPs() error {
-	vCh := ftpcli.NewFileChan(
-		c.flagSRC,
+func (c *cmdAve) downloadZIPs(ctx context.Context) error {
+	var err error
+	c.src, err = c.openSource(c.flagSRC)
+	if err != nil {
+		return err
+	}
+
+	vCh := c.src.Files(
+		ctx,
 		func(name string) bool {
 			return strings.Contains(strings.ToLower(name), timeFmt)
 		},
@@ -119,45 +109,46 @@ func (c *cmdAve) downloadZIPs() error {
 	return nil
 }
 
-func (c *cmdAve) deleteZIPs() error {
+func (c *cmdAve) deleteZIPs(ctx context.Context) error {
 	f := make([]string, 0, len(c.mapFile))
 	for k := range c.mapFile {
 		f = append(f, k)
 	}
-	return ftpcli.Delete(c.flagSRC, f...)
+	return c.src.Delete(ctx, f...)
 }
 
-func (c *cmdAve) transformCSVs() error {
+func (c *cmdAve) transformCSVs(ctx context.Context) error {
 	for i := range walkWay {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		s := walkWay[i]
 		f, ok := c.mapFile[s]
 		if !ok {
 			return fmt.Errorf("ave: file not found '%v'", s)
 		}
 
-		rc, err := ziputil.ExtractFile(f)
+		rc, err := ziputil.ExtractOne(f, s, c.spool(), "*.csv")
 		if err != nil {
 			return err
 		}
 
-		vCh := csvutil.NewRecordChan(txtutil.Win1251ToUTF8(rc), ';', false, 1)
-		for v := range vCh {
-			if v.Error != nil {
-				continue
-			}
-			switch {
-			case s == fileApt:
-				err = c.parseRecordApt(v.Record)
-			case s == fileTov:
-				err = c.parseRecordTov(v.Record)
-			case s == fileOst:
-				err = c.parseRecordOst(v.Record)
-			}
-			if err != nil {
-				return err
-			}
+		var parse func([]string) error
+		switch {
+		case s == fileApt:
+			parse = c.parseRecordApt
+		case s == fileTov:
+			parse = c.parseRecordTov
+		case s == fileOst:
+			parse = c.parseRecordOst
 		}
+
+		vCh := csvutil.NewRecordChan(txtutil.Win1251ToUTF8(rc), ';', false, 1)
+		err = c.parseRecords(s, vCh, parse)
 		_ = rc.Close()
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -166,10 +157,10 @@ func (c *cmdAve) transformCSVs() error {
 func (c *cmdAve) parseRecordApt(r []string) error {
 	csvLen := 3
 	if len(r) < csvLen {
-		return fmt.Errorf("invalid csv: got %d, want %d", len(r), csvLen)
+		return rejectRow(reasonShort)
 	}
 
-	s := shop{
+	s := model.Shop{
 		ID:   strings.TrimSpace(r[0]),
 		Name: strings.TrimSpace(r[1]),
 		Head: aveHead,
@@ -189,10 +180,10 @@ func (c *cmdAve) parseRecordApt(r []string) error {
 func (c *cmdAve) parseRecordTov(r []string) error {
 	csvLen := 2
 	if len(r) < csvLen {
-		return fmt.Errorf("invalid csv: got %d, want %d", len(r), csvLen)
+		return rejectRow(reasonShort)
 	}
 
-	d := drug{
+	d := model.Product{
 		ID:   strings.TrimSpace(r[0]),
 		Name: strings.TrimSpace(r[1]),
 	}
@@ -205,72 +196,51 @@ func (c *cmdAve) parseRecordTov(r []string) error {
 func (c *cmdAve) parseRecordOst(r []string) error {
 	csvLen := 4
 	if len(r) < csvLen {
-		return fmt.Errorf("invalid csv: got %d, want %d", len(r), csvLen)
+		return rejectRow(reasonShort)
 	}
 
 	s, ok := c.mapShop[strings.TrimSpace(r[1])]
 	if !ok {
-		return fmt.Errorf("shop not found %s", r[1])
+		return unknownRef(refShop, strings.TrimSpace(r[1]))
 	}
 
 	d, ok := c.mapDrug[strings.TrimSpace(r[0])]
 	if !ok {
-		return fmt.Errorf("drug not found %s", r[0])
+		return unknownRef(refDrug, strings.TrimSpace(r[0]))
 	}
 
 	quant, err := strconv.ParseFloat(strings.TrimSpace(r[2]), 64)
 	if err != nil {
-		fmt.Println(err, r[0], r[1], r[2], r[3])
-		//return err
+		return rejectRow(reasonNumber)
 	}
 	price, err := strconv.ParseFloat(strings.TrimSpace(r[3]), 64)
 	if err != nil {
-		fmt.Println(err, r[0], r[1], r[2], r[3])
-		//return err
+		return rejectRow(reasonNumber)
 	}
 
-	p := prop{
-		ID:    d.ID,
-		Name:  d.Name,
-		Quant: quant,
-		Price: price,
+	p := model.Stock{
+		Product: d,
+		Quant:   quant,
+		Price:   price,
 	}
 
 	c.mapProp[s.ID] = append(c.mapProp[s.ID], p)
 	return nil
 }
 
-func (c *cmdAve) uploadGzipJSONs() error {
-	b := new(bytes.Buffer)
-	w := gzip.NewWriter(b)
-
-	var n int
-	var err error
-	for k, v := range c.mapProp {
-		p := price{
-			Meta: c.mapShop[k],
-			Data: v,
-		}
-
-		b.Reset()
-		w.Reset(b)
-
-		err = json.NewEncoder(w).Encode(p)
-		if err != nil {
-			return err
-		}
-
-		err = w.Close()
-		if err != nil {
-			return err
-		}
+func (c *cmdAve) uploadGzipJSONs(ctx context.Context) error {
+	keys := make([]string, 0, len(c.mapProp))
+	for k := range c.mapProp {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 
-		n++
-		err = c.pushGzipV2(b, fmt.Sprintf("%s (%d) %s %d", c.name, n, p.Meta.ID, len(p.Data)))
-		if err != nil {
-			return err
+	return c.uploadAll(ctx, keys, func(ctx context.Context, k string, n int) error {
+		l := &model.StockList{
+			Shop:  c.mapShop[k],
+			Items: c.mapProp[k],
 		}
-	}
 
-	return nil
+		return c.pushStock(ctx, l, k, n)
+	})
 }
@@ -2,235 +2,190 @@ package run
 
 import (
 	"bytes"
-	"compress/gzip"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
 
 	"internal/archive/ziputil"
-	"internal/net/ftpcli"
+	"internal/encoding/dbfutil"
+	"internal/model"
+	"internal/net/source"
 
-	"github.com/CentaurWarchief/dbf"
 	"golang.org/x/text/encoding/charmap"
 	"golang.org/x/text/transform"
 )
 
-// Data structs
-
-type meta struct {
-	Timestamp   string `json:",omitempty"`
-	TRangeLower string `json:",omitempty"`
-	TRangeUpper string `json:",omitempty"`
-}
-
-type item struct {
-	Code     string  `json:",omitempty"`
-	Drug     string  `json:",omitempty"`
-	QuantInp float64 `json:",omitempty"`
-	QuantOut float64 `json:",omitempty"`
-	PriceInp float64 `json:",omitempty"`
-	PriceOut float64 `json:",omitempty"`
-	PriceRoc float64 `json:",omitempty"`
-	Balance  float64 `json:",omitempty"`
-	BalanceT float64 `json:",omitempty"`
-}
-
-type head struct {
-	Source    string `json:",omitempty"`
-	Drugstore string `json:",omitempty"`
-}
-
-type data struct {
-	Head head   `json:",omitempty"`
-	Item []item `json:",omitempty"`
-}
-
-type priceOld struct {
-	Meta meta   `json:",omitempty"`
-	Data []data `json:",omitempty"`
-}
-
 // Command
 
 type cmdBel struct {
 	cmdBase
 
-	mapFile map[string]ftpcli.Filer
+	mapFile map[string]source.Filer
+	mapSrcs map[string]source.Sourcer
 	mapDele map[string][]string // for clean up
-	mapJSON map[string]priceOld
+	mapJSON map[string]*model.Sales
 }
 
 func NewCmdBel() *cmdBel {
 	cmd := &cmdBel{
-		mapFile: make(map[string]ftpcli.Filer, 100),
+		mapFile: make(map[string]source.Filer, 100),
+		mapSrcs: make(map[string]source.Sourcer, 10),
 		mapDele: make(map[string][]string, 100),
-		mapJSON: make(map[string]priceOld, 100),
+		mapJSON: make(map[string]*model.Sales, 100),
 	}
 	cmd.mustInitBase(cmd, "bel", "download, transform and send to skynet zip(dbf) files from ftp")
+	cmd.wire = model.PriceOld
 	return cmd
 }
 
-func (c *cmdBel) exec() error {
-	err := c.downloadZIPs()
+func (c *cmdBel) exec(ctx context.Context) error {
+	err := c.downloadZIPs(ctx)
 	if err != nil {
 		return err
 	}
 
-	err = c.transformDBFs()
+	err = c.transformDBFs(ctx)
 	if err != nil {
 		return err
 	}
 
-	return c.uploadGzipJSONs()
+	err = c.uploadGzipJSONs(ctx)
+	if err != nil {
+		return err
+	}
 
-	//err = c.deleteZIPs()
-	//if err != nil {
-	//	return err
-	//}
+	return c.deleteZIPs(ctx)
 }
 
-func (c *cmdBel) downloadZIPs() error {
+func (c *cmdBel) downloadZIPs(ctx context.Context) error {
 	splitFlag := strings.Split(c.flagSRC, ",")
 	for i := range splitFlag {
-		vCh := ftpcli.NewFileChan(
-			splitFlag[i],
+		src, err := c.openSource(splitFlag[i])
+		if err != nil {
+			return err
+		}
+		c.mapSrcs[splitFlag[i]] = src
+
+		vCh := src.Files(
+			ctx,
 			nil,
-			true,
+			false,
 		)
 
 		for v := range vCh {
 			if v.Error != nil {
 				return v.Error
 			}
-			c.mapFile[v.File.Name()] = v.File
+			// processed files are deleted without processing
 			c.mapDele[splitFlag[i]] = append(c.mapDele[splitFlag[i]], v.File.Name())
+			done, err := c.processed(v.File)
+			if err != nil {
+				return err
+			}
+			if done {
+				_ = v.File.Close()
+				continue
+			}
+			c.mapFile[v.File.Name()] = v.File
 		}
 	}
 
 	return nil
 }
 
-func (c *cmdBel) deleteZIPs() error {
+func (c *cmdBel) deleteZIPs(ctx context.Context) error {
 	for k, v := range c.mapDele {
 		if v == nil {
 			continue
 		}
-		return ftpcli.Delete(k, v...)
+		err := c.mapSrcs[k].Delete(ctx, v...)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (c *cmdBel) transformDBFs() error {
+func (c *cmdBel) transformDBFs(ctx context.Context) error {
 	for k, v := range c.mapFile {
-		rc, err := ziputil.ExtractFile(v)
-		if err != nil {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
-
-		f, err := ioutil.ReadAll(rc)
+		f, err := ziputil.ExtractOne(v, k, c.spool(), "*.dbf")
 		if err != nil {
 			return err
 		}
-		_ = rc.Close()
-
-		t, err := dbf.NewTableFromReader(bytes.NewReader(f))
-		if err != nil {
-			return err
-		}
-		l := t.ReadAll()
 
 		var (
-			name, date string
-			items      = make([]item, 0, len(l))
-			cp866      = &cp866Decoder{new(bytes.Buffer)}
+			sales = &model.Sales{
+				Source: "file:" + k,
+				Time:   time.Now(),
+				Items:  make([]model.Sale, 0),
+			}
+			cp866 = &cp866Decoder{new(bytes.Buffer)}
 		)
 
-		for i := range l {
-			if i == 0 {
-				name = cp866.DecodeString(intfToString(l[i]["APTEKA"]))
-				date = intfToTimeAsString(l[i]["DATE"])
+		err = readDBF(f, func(t *dbfutil.Table, n int, r map[string]interface{}) {
+			if n == 1 {
+				sales.Shop.Name = cp866.DecodeString(intfToString(r["APTEKA"]))
+				if t, ok := r["DATE"].(time.Time); ok {
+					sales.From = t
+					sales.To = t.Add(24*time.Hour - time.Second)
+				}
 			}
 
-			items = append(items, item{
-				Code: "",
-				Drug: drugPlusMaker(
-					cp866.DecodeString(intfToString(l[i]["TOVAR"])),
-					cp866.DecodeString(intfToString(l[i]["PROIZV"])),
-				),
-				QuantInp: intfToFloat64(l[i]["APTIN"]),
-				QuantOut: intfToFloat64(l[i]["OUT"]),
-				PriceInp: intfToFloat64(l[i]["PRICEIN"]),
-				PriceOut: intfToFloat64(l[i]["PRICE"]),
-				PriceRoc: intfToFloat64(l[i]["ROC"]),
-				Balance:  intfToFloat64(l[i]["KOLSTAT"]),
-				BalanceT: intfToFloat64(l[i]["AMOUNT"]),
-			})
-		}
+			if !intfAreNumbers(r, "APTIN", "OUT", "PRICEIN", "PRICE", "ROC", "KOLSTAT", "AMOUNT") {
+				c.reject(k, n, rejectRow(reasonNumber), dbfValues(t, r, cp866))
+				return
+			}
 
-		c.mapJSON[k] = priceOld{
-			Meta: meta{
-				Timestamp:   time.Now().Format("02.01.2006 15:04:05.999999999"),
-				TRangeLower: date + " 00:00:00",
-				TRangeUpper: date + " 23:59:59",
-			},
-			Data: []data{
-				{
-					Head: head{
-						Source:    "file:" + k,
-						Drugstore: name,
-					},
-					Item: items,
+			sales.Items = append(sales.Items, model.Sale{
+				Product: model.Product{
+					Name: drugPlusMaker(
+						cp866.DecodeString(intfToString(r["TOVAR"])),
+						cp866.DecodeString(intfToString(r["PROIZV"])),
+					),
 				},
-			},
+				QuantIn:  intfToFloat64(r["APTIN"]),
+				QuantOut: intfToFloat64(r["OUT"]),
+				PriceIn:  intfToFloat64(r["PRICEIN"]),
+				PriceOut: intfToFloat64(r["PRICE"]),
+				Markup:   intfToFloat64(r["ROC"]),
+				Balance:  intfToFloat64(r["KOLSTAT"]),
+				Amount:   intfToFloat64(r["AMOUNT"]),
+			})
+			c.report.accept(k)
+		})
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", k, err)
 		}
+
+		c.mapJSON[k] = sales
 	}
 
 	return nil
 }
 
-func (c *cmdBel) uploadGzipJSONs() error {
-	b := new(bytes.Buffer)
-	w := gzip.NewWriter(b)
-
+func (c *cmdBel) uploadGzipJSONs(ctx context.Context) error {
 	var n int
 	var err error
-	for _, v := range c.mapJSON {
-		b.Reset()
-		w.Reset(b)
-
-		err = json.NewEncoder(w).Encode(v)
-		if err != nil {
-			return err
-		}
-
-		err = w.Close()
-		if err != nil {
-			return err
-		}
+	for k := range c.mapJSON {
+		c.expect(strings.TrimSuffix(k, filepath.Ext(k)))
+	}
 
+	for k, v := range c.mapJSON {
 		n++
-		s := fmt.Sprintf("%s (%d)", c.name, n)
-		if len(v.Data) > 0 {
-			s = fmt.Sprintf("%s %s %d", s, v.Data[0].Head.Source, len(v.Data[0].Item))
-		} else {
-			s = fmt.Sprintf("%s %s %d", s, "?", 0)
-		}
-
-		err = c.pushGzipV1(b, s)
+		err = c.pushSales(ctx, v, strings.TrimSuffix(k, filepath.Ext(k)), n)
 		if err != nil {
 			return err
 		}
-
-		//err = ioutil.WriteFile(strings.Replace(k, ".zip", ".json", -1)+".gz", b.Bytes(), 0666)
-		//if err != nil {
-		//	return err
-		//}
 	}
 
 	return nil
@@ -276,12 +231,57 @@ func intfToFloat64(v interface{}) float64 {
 	return f
 }
 
-func intfToTimeAsString(v interface{}) string {
-	if t, ok := v.(time.Time); ok {
-		return t.Format("02.01.2006")
+// intfAreNumbers reports whether fields are empty or numbers
+func intfAreNumbers(r map[string]interface{}, name ...string) bool {
+	for i := range name {
+		s, ok := r[name[i]].(string)
+		if !ok || s == "" {
+			continue
+		}
+		_, err := strconv.ParseFloat(strings.Replace(s, ",", ".", -1), 64)
+		if err != nil {
+			return false
+		}
 	}
+	return true
+}
 
-	return ""
+// readDBF passes records of dbf table to fn one by one, n is number of
+// record starting from 1
+func readDBF(rd io.Reader, fn func(t *dbfutil.Table, n int, r map[string]interface{})) error {
+	t, err := dbfutil.NewTable(rd)
+	if err != nil {
+		return err
+	}
+
+	for n := 1; ; n++ {
+		r, err := t.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(t, n, r)
+	}
+}
+
+// dbfValues returns values of record in order of columns
+func dbfValues(t *dbfutil.Table, r map[string]interface{}, cp866 *cp866Decoder) []string {
+	names := t.ColumnNames()
+	l := make([]string, len(names))
+	for i := range names {
+		switch v := r[names[i]].(type) {
+		case string:
+			l[i] = cp866.DecodeString(v)
+		case time.Time:
+			l[i] = v.Format("02.01.2006")
+		case nil:
+		default:
+			l[i] = fmt.Sprint(v)
+		}
+	}
+	return l
 }
 
 func drugPlusMaker(name, maker string) string {
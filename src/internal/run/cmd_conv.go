@@ -0,0 +1,157 @@
+package run
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"internal/model"
+)
+
+type cmdConv struct {
+	cmdBase
+
+	flagFrom string
+	flagOut  string
+}
+
+func NewCmdConv() *cmdConv {
+	cmd := &cmdConv{}
+	cmd.mustInitBase(cmd, "convert", "convert gzip(json) payloads [file ...] to wire format -wire")
+	cmd.untracked = true
+	return cmd
+}
+
+func (c *cmdConv) setFlags(f *flag.FlagSet) {
+	f.StringVar(&c.flagFrom, "from", "", "wire format of input price, price1 or priceOld (default detected by content)")
+	f.StringVar(&c.flagOut, "out", "", "directory for converted payloads (default stdout)")
+}
+
+// failFast checks flags, nothing is sent to skynet
+func (c *cmdConv) failFast(ctx context.Context) error {
+	if c.wire == "" {
+		return fmt.Errorf("convert: -wire must be defined")
+	}
+	if c.flagFrom != "" {
+		_, err := model.ParseFormat(c.flagFrom)
+		return err
+	}
+	return nil
+}
+
+func (c *cmdConv) exec(ctx context.Context) error {
+	if len(c.args) == 0 {
+		return c.convert(os.Stdin, "stdin")
+	}
+
+	for _, v := range c.args {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		f, err := os.Open(v)
+		if err != nil {
+			return err
+		}
+		err = c.convert(f, v)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *cmdConv) convert(r io.Reader, name string) error {
+	b, err := readPayload(r)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	from := model.Format(c.flagFrom)
+	if from == "" {
+		from, err = model.Detect(b)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	l, err := from.Decode(b)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	for i := range l {
+		// fields lost in conversion are reported even if logs are off
+		if d := c.wire.Dropped(l[i]); len(d) > 0 {
+			fmt.Fprintln(os.Stderr, c.p.Sprintf("%s: %s -> %s: dropped %s", name, from, c.wire, strings.Join(d, ", ")))
+		}
+
+		v, err := c.wire.EncodeSales(l[i])
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+
+		p, err := gzipJSON(v)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+
+		err = c.write(p, name, i, len(l))
+		if err != nil {
+			return err
+		}
+	}
+
+	c.log.Info("convert", "stage", stageTransform, "file", name, "from", from, "to", c.wire, "payloads", len(l))
+	return nil
+}
+
+// write writes payload n of total to -out as name.json.gz or name_n.json.gz
+func (c *cmdConv) write(p *bytes.Buffer, name string, n, total int) error {
+	if c.flagOut == "" {
+		_, err := p.WriteTo(os.Stdout)
+		return err
+	}
+
+	err := os.MkdirAll(c.flagOut, 0755)
+	if err != nil {
+		return err
+	}
+
+	s := filepath.Base(name)
+	s = strings.TrimSuffix(s, ".gz")
+	s = strings.TrimSuffix(s, ".json")
+	if total > 1 {
+		s = fmt.Sprintf("%s_%d", s, n+1)
+	}
+
+	return ioutil.WriteFile(filepath.Join(c.flagOut, s+".json.gz"), p.Bytes(), 0644)
+}
+
+// readPayload returns json of gzip(json) or plain json payload
+func readPayload(r io.Reader) ([]byte, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		return b, nil
+	}
+
+	z, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = z.Close() }()
+
+	return ioutil.ReadAll(z)
+}
@@ -0,0 +1,131 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"internal/i18n"
+	"internal/store/runstate"
+)
+
+// slack allows timer of digest to fire a bit earlier than period
+const slack = time.Hour
+
+type cmdDigest struct {
+	cmdBase
+
+	flagPeriod string
+	period     time.Duration
+	store      *runstate.Store
+}
+
+func NewCmdDigest() *cmdDigest {
+	cmd := &cmdDigest{}
+	cmd.mustInitBase(cmd, "digest", "send digest of the last runs of every command to notifiers")
+	cmd.untracked = true
+	return cmd
+}
+
+func (c *cmdDigest) setFlags(f *flag.FlagSet) {
+	f.StringVar(&c.flagPeriod, "period", "daily", "daily or weekly, digest is not sent twice within period")
+}
+
+// failFast checks flags, nothing is sent to skynet
+func (c *cmdDigest) failFast(ctx context.Context) error {
+	switch c.flagPeriod {
+	case "daily":
+		c.period = 24 * time.Hour
+	case "weekly":
+		c.period = 7 * 24 * time.Hour
+	default:
+		return fmt.Errorf("digest: unknown period '%s'", c.flagPeriod)
+	}
+
+	if c.flagState == "" {
+		return fmt.Errorf("digest: -state must be defined")
+	}
+	if len(c.notifiers) == 0 {
+		return fmt.Errorf("digest: -notify or -mgn must be defined")
+	}
+
+	var err error
+	c.store, err = runstate.Open(c.flagState)
+	return err
+}
+
+func (c *cmdDigest) exec(ctx context.Context) error {
+	last, err := c.store.Digested()
+	if err != nil {
+		return err
+	}
+	if time.Since(last) < c.period-slack {
+		c.log.Info("digest: sent already", "sent", last, "period", c.flagPeriod)
+		return nil
+	}
+
+	l, err := c.store.List()
+	if err != nil {
+		return err
+	}
+
+	n := c.newNotice(noticeDigest, c.p.Sprintf("DIGEST [%s]", c.p.Sprintf(c.flagPeriod)), nil, nil)
+	n.Text = digestText(c.p, l, n.Time.Add(-c.period))
+
+	if c.dryRun {
+		c.log.Info("dry-run: skip digest", "subject", n.Subject, "text", n.message().Text)
+		return nil
+	}
+
+	err = c.notifyAll(n.message())
+	if err != nil {
+		return err
+	}
+
+	return c.store.SetDigested(n.Time)
+}
+
+// digestText returns table of states, commands which did not run since
+// are marked
+func digestText(p *i18n.Printer, l []*runstate.State, since time.Time) string {
+	if len(l) == 0 {
+		return p.Sprintf("no runs")
+	}
+
+	var shops, items int
+	b := new(bytes.Buffer)
+	w := tabwriter.NewWriter(b, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+		p.Sprintf("command"), p.Sprintf("status"), p.Sprintf("last run"), p.Sprintf("last ok"), p.Sprintf("shops"), p.Sprintf("items"))
+	for _, v := range l {
+		status := p.Sprintf("ok")
+		if v.Failing {
+			status = p.Sprintf("failing since %s (%d runs)", p.DateTime(v.Since), v.Failures)
+		}
+		if v.LastRun.Before(since) {
+			status += ", " + p.Sprintf("no runs in period")
+		}
+
+		lastOK := p.Sprintf("never")
+		if !v.LastOK.IsZero() {
+			lastOK = p.DateTime(v.LastOK)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", v.Command, status, p.DateTime(v.LastRun), lastOK, p.Sprintf("%d", v.Shops), p.Sprintf("%d", v.Items))
+		shops += v.Shops
+		items += v.Items
+	}
+	_ = w.Flush()
+
+	fmt.Fprintf(b, "\n%s\n", p.Sprintf("total: %s, %s", p.Sprintf("%d shops", shops), p.Sprintf("%d items", items)))
+	for _, v := range l {
+		if v.Failing {
+			fmt.Fprintf(b, "\n%s: %s", v.Command, v.Error)
+		}
+	}
+
+	return b.String()
+}
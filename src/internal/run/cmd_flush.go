@@ -0,0 +1,35 @@
+package run
+
+import (
+	"context"
+	"flag"
+	"fmt"
+)
+
+type cmdFlush struct {
+	cmdBase
+
+	flagCmd string
+}
+
+func NewCmdFlush() *cmdFlush {
+	cmd := &cmdFlush{}
+	cmd.mustInitBase(cmd, "flush", "send to skynet payloads kept in outbox")
+	return cmd
+}
+
+func (c *cmdFlush) setFlags(f *flag.FlagSet) {
+	f.StringVar(&c.flagCmd, "cmd", "", "flush payloads of command only")
+}
+
+// failFast does nothing, every entry in outbox knows its destination
+func (c *cmdFlush) failFast(ctx context.Context) error {
+	if c.flagOutbox == "" {
+		return fmt.Errorf("flush: outbox must be defined")
+	}
+	return nil
+}
+
+func (c *cmdFlush) exec(ctx context.Context) error {
+	return c.flushOutbox(ctx, c.flagCmd)
+}
@@ -1,12 +1,11 @@
 package run
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"strings"
-
-	"internal/net/mailcli"
 )
 
 // Command DEPRECATED
@@ -21,37 +20,54 @@ func NewCmdFoz() *cmdFoz {
 	return cmd
 }
 
-func (c *cmdFoz) exec() error {
-	return c.downloadAndPushGzips()
+func (c *cmdFoz) exec(ctx context.Context) error {
+	return c.downloadAndPushGzips(ctx)
 }
 
-func (c *cmdFoz) downloadAndPushGzips() error {
-	vCh := mailcli.NewFileChan(
-		c.flagSRC,
+func (c *cmdFoz) downloadAndPushGzips(ctx context.Context) error {
+	src, err := c.openSource(c.flagSRC)
+	if err != nil {
+		return err
+	}
+
+	vCh := src.Files(
+		ctx,
 		func(name string) bool {
 			return strings.HasPrefix(strings.ToLower(filepath.Ext(name)), ".gz")
 		},
-		true,
+		false,
 	)
 
 	var n int
+	var names []string
 	for v := range vCh {
 		if v.Error != nil {
 			return v.Error
 		}
 
-		if key, tag, ok := extractKeyTag(v.File.Subj()); ok {
-			c.flagKey, c.flagTag = key, tag
+		// processed files are deleted without pushing
+		names = append(names, v.File.Name())
+		done, err := c.processed(v.File)
+		if err != nil {
+			return err
+		}
+		if done {
+			_ = v.File.Close()
+			continue
+		}
+
+		if key, tag, ok := extractKeyTag(v.File.Origin().Subj); ok {
+			c.setKeyTag(key, tag)
 		}
 
 		n++
-		err := c.pushGzipV1(v.File, fmt.Sprintf("%s (%d) %s", c.name, n, v.File.Name()))
+		err = c.pushGzipV1(ctx, v.File, v.File.Name(), fmt.Sprintf("%s (%d) %s", c.name, n, v.File.Name()))
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return src.Delete(ctx, names...)
 }
 
 // Util func
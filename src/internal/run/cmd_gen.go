@@ -0,0 +1,501 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"internal/archive/ziputil"
+	"internal/encoding/csvutil"
+	"internal/encoding/txtutil"
+	"internal/model"
+	"internal/net/source"
+)
+
+const (
+	kindShop  = "shop"
+	kindDrug  = "drug"
+	kindStock = "stock"
+
+	archiveZip   = "zip"
+	archiveGz    = "gz"
+	archiveTar   = "tar"
+	archiveTarGz = "tar.gz" // format is detected by content, it is checked for typos only
+)
+
+// joinKinds are kinds of files referenced by fields of stock
+var joinKinds = map[string]string{
+	"shop": kindShop,
+	"drug": kindDrug,
+}
+
+// Job structs
+
+// genJob is a declarative description of a supplier feed (see etc/jobs)
+type genJob struct {
+	Source string    `json:"source,omitempty"` // overrides -src if -src is empty
+	Head   string    `json:"head,omitempty"`   // default shop head
+	Wire   string    `json:"wire,omitempty"`   // price (v2), price1 (v1) or priceOld (v1), -wire overrides it
+	Delete bool      `json:"delete,omitempty"` // delete source files after push
+	Files  []genFile `json:"files"`            // strong order: shops, drugs, stocks
+}
+
+// genFile describes one file of a feed and how its columns map to fields
+type genFile struct {
+	Name     string            `json:"name"`               // logical name used by joins
+	Kind     string            `json:"kind"`               // shop, drug or stock
+	Mask     string            `json:"mask"`               // path.Match pattern, {date} is replaced
+	Date     string            `json:"date,omitempty"`     // time layout for {date}, e.g. 02.01.06
+	Archive  string            `json:"archive,omitempty"`  // "", zip, gz, tar or tar.gz
+	Entry    string            `json:"entry,omitempty"`    // path.Match pattern of file in archive
+	Encoding string            `json:"encoding,omitempty"` // utf8, win1251 or cp866
+	Comma    string            `json:"comma,omitempty"`    // default ;
+	Quotes   bool              `json:"lazyQuotes,omitempty"`
+	Skip     int               `json:"skip,omitempty"` // header lines
+	Fields   map[string][]int  `json:"fields"`         // field -> columns joined with space
+	Join     map[string]string `json:"join,omitempty"` // field -> name of referenced file
+	mask     string            // with resolved {date}
+	comma    rune
+	csvLen   int
+}
+
+// Command
+
+type cmdGen struct {
+	cmdBase
+
+	flagJob string
+
+	job     genJob
+	src     source.Sourcer
+	mapFile map[string]source.Filer
+	mapName map[string]string
+	mapShop map[string]map[string]model.Shop
+	mapDrug map[string]map[string]model.Product
+	mapProp map[string][]model.Stock
+}
+
+func NewCmdGen() *cmdGen {
+	cmd := &cmdGen{
+		mapFile: make(map[string]source.Filer, capFile),
+		mapName: make(map[string]string, capFile),
+		mapShop: make(map[string]map[string]model.Shop, capFile),
+		mapDrug: make(map[string]map[string]model.Product, capFile),
+		mapProp: make(map[string][]model.Stock, capProp),
+	}
+	cmd.mustInitBase(cmd, "generic", "download, transform and send to skynet files described by job file")
+	cmd.wire = model.Price
+	return cmd
+}
+
+func (c *cmdGen) setFlags(f *flag.FlagSet) {
+	f.StringVar(&c.flagJob, "job", "", "job file (json)")
+}
+
+// jobName returns generic/<job file without extension>, so runs of
+// different jobs do not share state, snapshots, outbox and reports
+func (c *cmdGen) jobName() string {
+	s := strings.TrimSuffix(filepath.Base(c.flagJob), filepath.Ext(c.flagJob))
+	if c.flagJob == "" || s == "" {
+		return c.name
+	}
+	return c.name + "/" + s
+}
+
+func (c *cmdGen) exec(ctx context.Context) error {
+	err := c.loadJob()
+	if err != nil {
+		return err
+	}
+
+	err = c.downloadFiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	done, err := c.allProcessed(filesOf(c.mapFile)...)
+	if err != nil {
+		return err
+	}
+	if done {
+		c.log.Info("nothing new", "stage", stagePull)
+		return c.deleteFiles(ctx)
+	}
+
+	err = c.transformFiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = c.uploadGzipJSONs(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.deleteFiles(ctx)
+}
+
+func (c *cmdGen) loadJob() error {
+	f, err := os.Open(c.flagJob)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	err = json.NewDecoder(f).Decode(&c.job)
+	if err != nil {
+		return fmt.Errorf("generic: job %s: %v", c.flagJob, err)
+	}
+
+	if c.flagSRC == "" {
+		c.flagSRC = c.job.Source
+	}
+
+	return c.checkJob()
+}
+
+func (c *cmdGen) checkJob() error {
+	if c.job.Wire != "" && c.flagWire == "" {
+		var err error
+		c.wire, err = model.ParseFormat(c.job.Wire)
+		if err != nil {
+			return fmt.Errorf("generic: %v", err)
+		}
+	}
+
+	names := make(map[string]string, len(c.job.Files))
+	for i := range c.job.Files {
+		f := &c.job.Files[i]
+		if f.Name == "" || f.Mask == "" {
+			return fmt.Errorf("generic: file %d: name and mask must be defined", i)
+		}
+
+		switch f.Kind {
+		case kindShop, kindDrug:
+			if _, ok := f.Fields["id"]; !ok {
+				return fmt.Errorf("generic: file %s: field 'id' must be defined", f.Name)
+			}
+		case kindStock:
+			for _, v := range []string{"shop", "drug", "quant", "price"} {
+				if _, ok := f.Fields[v]; !ok {
+					return fmt.Errorf("generic: file %s: field '%s' must be defined", f.Name, v)
+				}
+			}
+		default:
+			return fmt.Errorf("generic: file %s: unknown kind '%s'", f.Name, f.Kind)
+		}
+
+		for k, v := range f.Join {
+			kind := joinKinds[k]
+			if f.Kind != kindStock || kind == "" {
+				return fmt.Errorf("generic: file %s: join %s: only shop and drug of stock can be joined", f.Name, k)
+			}
+			switch names[v] {
+			case kind:
+			case "":
+				return fmt.Errorf("generic: file %s: join %s: file '%s' must be described before", f.Name, k, v)
+			default:
+				return fmt.Errorf("generic: file %s: join %s: file '%s' must be %s, not %s", f.Name, k, v, kind, names[v])
+			}
+		}
+		names[f.Name] = f.Kind
+
+		f.mask = strings.ToLower(f.Mask)
+		if f.Date != "" {
+			f.mask = strings.Replace(f.mask, "{date}", strings.ToLower(time.Now().Format(f.Date)), -1)
+		}
+
+		for k, v := range f.Fields {
+			for _, i := range v {
+				if i < 0 {
+					return fmt.Errorf("generic: file %s: field '%s': negative column %d", f.Name, k, i)
+				}
+				if i+1 > f.csvLen {
+					f.csvLen = i + 1
+				}
+			}
+		}
+
+		switch f.Archive {
+		case "", archiveZip, archiveGz, archiveTar, archiveTarGz:
+		default:
+			return fmt.Errorf("generic: file %s: unknown archive '%s'", f.Name, f.Archive)
+		}
+		if _, err := path.Match(f.Entry, ""); err != nil {
+			return fmt.Errorf("generic: file %s: entry: %v", f.Name, err)
+		}
+
+		f.comma = ';'
+		if f.Comma != "" {
+			f.comma, _ = utf8.DecodeRuneInString(f.Comma)
+		}
+
+		if _, err := path.Match(f.mask, ""); err != nil {
+			return fmt.Errorf("generic: file %s: mask: %v", f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *cmdGen) matchFile(name string) (string, bool) {
+	for i := range c.job.Files {
+		if ok, _ := path.Match(c.job.Files[i].mask, strings.ToLower(name)); ok {
+			return c.job.Files[i].Name, true
+		}
+	}
+	return "", false
+}
+
+func (c *cmdGen) downloadFiles(ctx context.Context) error {
+	var err error
+	c.src, err = c.openSource(c.flagSRC)
+	if err != nil {
+		return err
+	}
+
+	vCh := c.src.Files(
+		ctx,
+		func(name string) bool {
+			_, ok := c.matchFile(name)
+			return ok
+		},
+		false,
+	)
+
+	// an ambiguous match fails the run, the rest of files is read anyway,
+	// so source is not left in the middle
+	for v := range vCh {
+		if v.Error != nil {
+			return v.Error
+		}
+		s, ok := c.matchFile(v.File.Name())
+		if !ok {
+			continue
+		}
+		if prev, ok := c.mapName[s]; ok {
+			if err == nil {
+				err = fmt.Errorf("generic: file %s: several files match mask: %s, %s", s, prev, v.File.Name())
+			}
+			continue
+		}
+		c.mapFile[s] = v.File
+		c.mapName[s] = v.File.Name()
+	}
+
+	return err
+}
+
+func (c *cmdGen) deleteFiles(ctx context.Context) error {
+	if !c.job.Delete {
+		return nil
+	}
+
+	f := make([]string, 0, len(c.mapName))
+	for _, v := range c.mapName {
+		f = append(f, v)
+	}
+	return c.src.Delete(ctx, f...)
+}
+
+func (c *cmdGen) transformFiles(ctx context.Context) error {
+	for i := range c.job.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := c.transformFile(&c.job.Files[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// transformFile parses source file of f, an extracted file is closed
+// before the next one is extracted
+func (c *cmdGen) transformFile(f *genFile) error {
+	var r io.Reader
+	r, ok := c.mapFile[f.Name]
+	if !ok {
+		return fmt.Errorf("generic: file not found '%v'", f.Mask)
+	}
+
+	var rc io.ReadCloser
+	if f.Archive != "" {
+		var entry []string
+		if f.Entry != "" {
+			entry = append(entry, f.Entry)
+		}
+		var err error
+		rc, err = ziputil.ExtractOne(r, c.mapName[f.Name], c.spool(), entry...)
+		if err != nil {
+			return err
+		}
+		r = rc
+	}
+
+	r, err := txtutil.ToUTF8(r, f.Encoding)
+	if err == nil {
+		vCh := csvutil.NewRecordChan(r, f.comma, f.Quotes, f.Skip)
+		err = c.parseRecords(c.mapName[f.Name], vCh, func(r []string) error {
+			return c.parseRecord(f, r)
+		})
+	}
+
+	if rc != nil {
+		_ = rc.Close()
+	}
+	return err
+}
+
+func (c *cmdGen) parseRecord(f *genFile, r []string) error {
+	if len(r) < f.csvLen {
+		return rejectRow(reasonShort)
+	}
+
+	switch f.Kind {
+	case kindShop:
+		return c.parseRecordShop(f, r)
+	case kindDrug:
+		return c.parseRecordDrug(f, r)
+	case kindStock:
+		return c.parseRecordStock(f, r)
+	}
+	return nil
+}
+
+func (c *cmdGen) parseRecordShop(f *genFile, r []string) error {
+	s := model.Shop{
+		ID:   genField(f, r, "id"),
+		Name: genField(f, r, "name"),
+		Head: genField(f, r, "head"),
+		Addr: genField(f, r, "addr"),
+		Code: genField(f, r, "code"),
+	}
+
+	if s.Head == "" {
+		s.Head = c.job.Head
+	}
+
+	// special tuning if name is empty
+	if s.Name == "" {
+		s.Name = s.Head
+	}
+
+	if c.mapShop[f.Name] == nil {
+		c.mapShop[f.Name] = make(map[string]model.Shop, capShop)
+	}
+	c.mapShop[f.Name][s.ID] = s
+	return nil
+}
+
+func (c *cmdGen) parseRecordDrug(f *genFile, r []string) error {
+	d := model.Product{
+		ID:   genField(f, r, "id"),
+		Name: genField(f, r, "name"),
+	}
+
+	if c.mapDrug[f.Name] == nil {
+		c.mapDrug[f.Name] = make(map[string]model.Product, capDrug)
+	}
+	c.mapDrug[f.Name][d.ID] = d
+	return nil
+}
+
+func (c *cmdGen) parseRecordStock(f *genFile, r []string) error {
+	id := genField(f, r, "shop")
+	if v, ok := f.Join["shop"]; ok {
+		s, ok := c.mapShop[v][id]
+		if !ok {
+			return unknownRef(refShop, id)
+		}
+		id = s.ID
+	}
+
+	d := model.Product{
+		ID:   genField(f, r, "drug"),
+		Name: genField(f, r, "name"),
+	}
+	if v, ok := f.Join["drug"]; ok {
+		d, ok = c.mapDrug[v][d.ID]
+		if !ok {
+			return unknownRef(refDrug, genField(f, r, "drug"))
+		}
+	}
+
+	quant, err := genFloat(genField(f, r, "quant"))
+	if err != nil {
+		return rejectRow(reasonNumber)
+	}
+	price, err := genFloat(genField(f, r, "price"))
+	if err != nil {
+		return rejectRow(reasonNumber)
+	}
+
+	p := model.Stock{
+		Product: d,
+		Quant:   quant,
+		Price:   price,
+	}
+
+	c.mapProp[id] = append(c.mapProp[id], p)
+	return nil
+}
+
+func (c *cmdGen) findShop(id string) model.Shop {
+	for _, v := range c.job.Files {
+		if s, ok := c.mapShop[v.Name][id]; ok {
+			return s
+		}
+	}
+	return model.Shop{ID: id, Name: c.job.Head, Head: c.job.Head}
+}
+
+func (c *cmdGen) uploadGzipJSONs(ctx context.Context) error {
+	keys := make([]string, 0, len(c.mapProp))
+	for k := range c.mapProp {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return c.uploadAll(ctx, keys, func(ctx context.Context, k string, n int) error {
+		l := &model.StockList{
+			Shop:  c.findShop(k),
+			Items: c.mapProp[k],
+		}
+
+		return c.pushStock(ctx, l, k, n)
+	})
+}
+
+// Util funcs
+
+func genField(f *genFile, r []string, name string) string {
+	cols := f.Fields[name]
+	s := make([]string, 0, len(cols))
+	for _, i := range cols {
+		if v := strings.TrimSpace(r[i]); v != "" {
+			s = append(s, v)
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+func genFloat(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(strings.Replace(s, ",", ".", -1), 64)
+}
@@ -0,0 +1,312 @@
+package run
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"internal/store/spool"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// jobsDir is etc/jobs of repository
+var jobsDir = filepath.Join("..", "..", "..", "etc", "jobs")
+
+// newTestGen returns generic command with job file and source directory,
+// it is ready to download and transform files
+func newTestGen(t *testing.T, job, dir string) *cmdGen {
+	c := NewCmdGen()
+	c.flagJob = job
+	c.flagSRC = "file://" + dir
+	c.flagSpoolM = spool.DefaultLimit >> 20
+	c.runName = c.jobName()
+	c.log = logger
+	c.p = printerFrom(context.Background())
+	c.report = newReport(c.runName, time.Now(), c.p)
+	c.rejects = newRejects(t.TempDir(), c.runName, time.Now())
+	t.Cleanup(func() {
+		_ = c.rejects.close()
+		c.inputs.close()
+	})
+	return c
+}
+
+func win1251(t *testing.T, s string) []byte {
+	b, err := charmap.Windows1251.NewEncoder().String(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []byte(b)
+}
+
+func writeFiles(t *testing.T, dir string, files map[string][]byte) {
+	for k, v := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, k), v, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func zipOf(t *testing.T, name string, b []byte) []byte {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	f, err := w.Create(name)
+	if err == nil {
+		_, err = f.Write(b)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGenJobFiles(t *testing.T) {
+	for _, v := range []struct {
+		file  string
+		name  string
+		head  string
+		joins int
+	}{
+		{"ave.json", "generic/ave", "АВЕ", 2},
+		{"stl.json", "generic/stl", "STL", 2},
+	} {
+		c := NewCmdGen()
+		c.flagJob = filepath.Join(jobsDir, v.file)
+
+		err := c.loadJob()
+		if err != nil {
+			t.Fatalf("%s: %v", v.file, err)
+		}
+		if c.jobName() != v.name || c.job.Head != v.head || len(c.job.Files) != 3 {
+			t.Errorf("%s: name %s, head %s, files %d", v.file, c.jobName(), c.job.Head, len(c.job.Files))
+		}
+		if f := c.job.Files[2]; f.Kind != kindStock || len(f.Join) != v.joins || f.csvLen != 4 {
+			t.Errorf("%s: stock %+v", v.file, f)
+		}
+	}
+}
+
+func TestGenCheckJob(t *testing.T) {
+	const (
+		shop = `{"name": "apt", "kind": "shop", "mask": "apt.csv", "fields": {"id": [0]}}`
+		drug = `{"name": "tov", "kind": "drug", "mask": "tov.csv", "fields": {"id": [0]}}`
+	)
+	for _, v := range []struct {
+		stock string
+		err   string
+	}{
+		{
+			`{"name": "ost", "kind": "stock", "mask": "ost.csv", "fields": {"shop": [0], "drug": [1], "quant": [2], "price": [3]}, "join": {"shop": "apt", "drug": "tov"}}`,
+			"",
+		},
+		{
+			`{"name": "ost", "kind": "stock", "mask": "ost.csv", "fields": {"shop": [0], "drug": [1], "quant": [2], "price": [3]}, "join": {"shop": "tov"}}`,
+			"join shop: file 'tov' must be shop, not drug",
+		},
+		{
+			`{"name": "ost", "kind": "stock", "mask": "ost.csv", "fields": {"shop": [0], "drug": [1], "quant": [2], "price": [3]}, "join": {"drug": "sp"}}`,
+			"join drug: file 'sp' must be described before",
+		},
+		{
+			`{"name": "ost", "kind": "stock", "mask": "ost.csv", "fields": {"shop": [0], "drug": [1], "quant": [2], "price": [3]}, "join": {"name": "tov"}}`,
+			"join name: only shop and drug of stock can be joined",
+		},
+		{
+			`{"name": "ost", "kind": "stock", "mask": "ost.csv", "fields": {"shop": [0], "quant": [2], "price": [3]}}`,
+			"field 'drug' must be defined",
+		},
+		{
+			`{"name": "ost", "kind": "stock", "mask": "ost.csv", "fields": {"shop": [0], "drug": [-1], "quant": [2], "price": [3]}}`,
+			"field 'drug': negative column -1",
+		},
+		{
+			`{"name": "ost", "kind": "stock", "mask": "ost.csv", "fields": {"shop": [0], "drug": [1], "quant": [2], "price": [3]}, "archive": "rar"}`,
+			"unknown archive 'rar'",
+		},
+	} {
+		job := filepath.Join(t.TempDir(), "job.json")
+		writeFiles(t, filepath.Dir(job), map[string][]byte{
+			"job.json": []byte(`{"files": [` + shop + `, ` + drug + `, ` + v.stock + `]}`),
+		})
+
+		c := NewCmdGen()
+		c.flagJob = job
+		err := c.loadJob()
+		switch {
+		case v.err == "" && err != nil:
+			t.Errorf("%s: %v", v.stock, err)
+		case v.err != "" && (err == nil || !strings.Contains(err.Error(), "generic: file ost: "+v.err)):
+			t.Errorf("%s: error %v, want %s", v.stock, err, v.err)
+		}
+	}
+}
+
+func TestGenTransform(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string][]byte{
+		"APT.csv": win1251(t, "id;name\n1;Аптека 1\n2;Аптека 2\n"),
+		"SP.csv":  win1251(t, "id;name;dose;unit\n10;Аспирин;500;мг\n11;Бинт;;\n"),
+		"OST.csv": win1251(t, "shop;drug;quant;price\n"+
+			"1;10;5;12,50\n"+
+			"1;11;1;3\n"+
+			"2;10;2;13\n"+
+			"3;10;1;1\n"+ // unknown shop
+			"1;99;1;1\n"+ // unknown drug
+			"1;11;x;1\n"+ // bad number
+			"1;11\n"), // short row
+		"README.txt": []byte("not a part of job"),
+	})
+
+	c := newTestGen(t, filepath.Join(jobsDir, "stl.json"), dir)
+	err := c.loadJob()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	err = c.downloadFiles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.mapFile) != 3 {
+		t.Fatalf("files %v", c.mapName)
+	}
+
+	err = c.transformFiles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.mapProp["1"]) != 2 || len(c.mapProp["2"]) != 1 || len(c.mapProp) != 2 {
+		t.Fatalf("stocks %v", c.mapProp)
+	}
+	if p := c.mapProp["1"][0]; p.Product.ID != "10" || p.Product.Name != "Аспирин 500 мг" || p.Quant != 5 || p.Price != 12.5 {
+		t.Errorf("stock %+v", p)
+	}
+	if s := c.findShop("2"); s.Name != "Аптека 2" || s.Head != "STL" {
+		t.Errorf("shop %+v", s)
+	}
+
+	f := c.report.file("OST.csv")
+	if f.Read != 7 || f.Accepted != 3 || f.Rejected != 4 {
+		t.Errorf("report %+v", f)
+	}
+	for k, v := range map[string]int{"unknown shop": 1, "unknown drug": 1, reasonNumber: 1, reasonShort: 1} {
+		if f.Reasons[k] != v {
+			t.Errorf("reason %s: %d, want %d", k, f.Reasons[k], v)
+		}
+	}
+	if c.report.Unknown[refShop]["3"] != 1 || c.report.Unknown[refDrug]["99"] != 1 {
+		t.Errorf("unknown %v", c.report.Unknown)
+	}
+
+	err = c.rejects.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(c.rejects.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "OST.csv,5,unknown shop,3") || !strings.Contains(filepath.Base(c.rejects.path), "generic_stl_") {
+		t.Errorf("rejects %s:\n%s", c.rejects.path, b)
+	}
+}
+
+func TestGenTransformArchive(t *testing.T) {
+	date := time.Now().Format("02.01.06")
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string][]byte{
+		"apt_" + date + ".zip": zipOf(t, "apt.csv", win1251(t, "id;name;addr\n1;Аптека 1;Ленина, 1\n")),
+		"tov_" + date + ".zip": zipOf(t, "tov.csv", win1251(t, "id;name\n10;Аспирин\n")),
+		"ost_" + date + ".zip": zipOf(t, "ost.csv", win1251(t, "drug;shop;quant;price\n10;1;5;12.5\n10;2;1;1\n")),
+		"ost_01.01.01.zip":     zipOf(t, "ost.csv", nil),
+	})
+
+	c := newTestGen(t, filepath.Join(jobsDir, "ave.json"), dir)
+	err := c.loadJob()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	err = c.downloadFiles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.transformFiles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.mapProp) != 1 || len(c.mapProp["1"]) != 1 {
+		t.Fatalf("stocks %v", c.mapProp)
+	}
+	if s := c.findShop("1"); s.Addr != "Ленина, 1" || s.Head != "АВЕ" {
+		t.Errorf("shop %+v", s)
+	}
+	if c.report.Unknown[refShop]["2"] != 1 {
+		t.Errorf("unknown %v", c.report.Unknown)
+	}
+}
+
+func TestGenAllRejected(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string][]byte{
+		"APT.csv": []byte("id;name\n1;A\n"),
+		"SP.csv":  []byte("id;name;dose;unit\n10;B;;\n"),
+		"OST.csv": []byte("shop,drug,quant,price\n1,10,1,1\n"), // comma is changed
+	})
+
+	c := newTestGen(t, filepath.Join(jobsDir, "stl.json"), dir)
+	err := c.loadJob()
+	if err == nil {
+		err = c.downloadFiles(context.Background())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.transformFiles(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "OST.csv: all 1 rows rejected") {
+		t.Fatalf("error %v", err)
+	}
+}
+
+func TestGenAmbiguous(t *testing.T) {
+	dir := t.TempDir()
+	job := filepath.Join(t.TempDir(), "ost.json")
+	writeFiles(t, filepath.Dir(job), map[string][]byte{
+		"ost.json": []byte(`{"files": [{"name": "ost", "kind": "stock", "mask": "ost*.csv", "fields": {"shop": [0], "drug": [1], "quant": [2], "price": [3]}}]}`),
+	})
+	writeFiles(t, dir, map[string][]byte{
+		"ost1.csv": []byte("1;10;1;1\n"),
+		"ost2.csv": []byte("1;10;1;1\n"),
+	})
+
+	c := newTestGen(t, job, dir)
+	err := c.loadJob()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.downloadFiles(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "generic: file ost: several files match mask: ost1.csv, ost2.csv") {
+		t.Fatalf("error %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "ost2.csv")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,67 @@
+package run
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"internal/store/ledger"
+)
+
+type cmdLedger struct {
+	cmdBase
+
+	flagCmd    string
+	flagForget string
+}
+
+func NewCmdLedger() *cmdLedger {
+	cmd := &cmdLedger{}
+	cmd.mustInitBase(cmd, "ledger", "list or forget source files processed by commands")
+	cmd.untracked = true
+	return cmd
+}
+
+func (c *cmdLedger) setFlags(f *flag.FlagSet) {
+	f.StringVar(&c.flagCmd, "cmd", "", "entries of command only")
+	f.StringVar(&c.flagForget, "forget", "", "forget files whose names match pattern (path.Match), so they are processed again")
+}
+
+// failFast checks flags, nothing is sent to skynet
+func (c *cmdLedger) failFast(ctx context.Context) error {
+	if c.flagLedger == "" {
+		return fmt.Errorf("ledger: -ledger must be defined")
+	}
+	return nil
+}
+
+func (c *cmdLedger) exec(ctx context.Context) error {
+	if c.flagForget == "" {
+		return c.print(c.ledger.List(c.flagCmd), "")
+	}
+
+	l, err := c.ledger.Forget(c.flagCmd, c.flagForget)
+	if err != nil {
+		return err
+	}
+	return c.print(l, c.p.Sprintf("forget")+" ")
+}
+
+func (c *cmdLedger) print(l []ledger.Entry, prefix string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, v := range l {
+		fmt.Fprintf(w, "%s%s\t%s\t%s\t%d\t%s\t%.12s\t%s\n",
+			prefix,
+			v.Command,
+			v.Origin,
+			v.Name,
+			v.Size,
+			c.p.DateTime(v.MTime),
+			v.Hash,
+			c.p.DateTime(v.Time),
+		)
+	}
+	return w.Flush()
+}
@@ -1,16 +1,16 @@
 package run
 
 import (
-	"bytes"
-	"compress/gzip"
-	"encoding/json"
+	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
 	"internal/encoding/csvutil"
 	"internal/encoding/txtutil"
-	"internal/net/ftpcli"
+	"internal/model"
+	"internal/net/source"
 )
 
 const (
@@ -23,46 +23,63 @@ type cmdStl struct {
 	cmdBase
 	files []string
 
-	mapFile map[string]ftpcli.Filer
-	mapShop map[string]shop
-	mapDrug map[string]drug
-	mapProp map[string][]prop
+	src     source.Sourcer
+	mapFile map[string]source.Filer
+	mapShop map[string]model.Shop
+	mapDrug map[string]model.Product
+	mapProp map[string][]model.Stock
 }
 
 func NewCmdStl() *cmdStl {
 	cmd := &cmdStl{
 		files:   []string{"APT.csv", "SP.csv", "OST.csv"},
-		mapFile: make(map[string]ftpcli.Filer, 3),
-		mapShop: make(map[string]shop, 20),
-		mapDrug: make(map[string]drug, 10000),
-		mapProp: make(map[string][]prop, 100000),
+		mapFile: make(map[string]source.Filer, 3),
+		mapShop: make(map[string]model.Shop, 20),
+		mapDrug: make(map[string]model.Product, 10000),
+		mapProp: make(map[string][]model.Stock, 100000),
 	}
 	cmd.mustInitBase(cmd, "stl", "download, transform and send to skynet zip(csv) files from ftp")
+	cmd.wire = model.Price
 	return cmd
 }
 
-func (c *cmdStl) exec() error {
-	err := c.downloadCSVs()
+func (c *cmdStl) exec(ctx context.Context) error {
+	err := c.downloadCSVs(ctx)
 	if err != nil {
 		return err
 	}
 
-	err = c.transformCSVs()
+	done, err := c.allProcessed(filesOf(c.mapFile)...)
+	if err != nil {
+		return err
+	}
+	if done {
+		c.log.Info("nothing new", "stage", stagePull)
+		return c.deleteCSVs(ctx)
+	}
+
+	err = c.transformCSVs(ctx)
 	if err != nil {
 		return err
 	}
 
-	err = c.uploadGzipJSONs()
+	err = c.uploadGzipJSONs(ctx)
 	if err != nil {
 		return err
 	}
 
-	return c.deleteCSVs()
+	return c.deleteCSVs(ctx)
 }
 
-func (c *cmdStl) downloadCSVs() error {
-	vCh := ftpcli.NewFileChan(
-		c.flagSRC,
+func (c *cmdStl) downloadCSVs(ctx context.Context) error {
+	var err error
+	c.src, err = c.openSource(c.flagSRC)
+	if err != nil {
+		return err
+	}
+
+	vCh := c.src.Files(
+		ctx,
 		nil,
 		false,
 	)
@@ -77,35 +94,35 @@ func (c *cmdStl) downloadCSVs() error {
 	return nil
 }
 
-func (c *cmdStl) deleteCSVs() error {
-	return ftpcli.Delete(c.flagSRC, c.files...)
+func (c *cmdStl) deleteCSVs(ctx context.Context) error {
+	return c.src.Delete(ctx, c.files...)
 }
 
-func (c *cmdStl) transformCSVs() error {
+func (c *cmdStl) transformCSVs(ctx context.Context) error {
 	for i := range c.files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		s := c.files[i]
 		f, ok := c.mapFile[s]
 		if !ok {
 			return fmt.Errorf("stl: file not found '%v'", s)
 		}
 
+		var parse func([]string) error
+		switch i {
+		case 0:
+			parse = c.parseRecordApt
+		case 1:
+			parse = c.parseRecordSp
+		case 2:
+			parse = c.parseRecordOst
+		}
+
 		vCh := csvutil.NewRecordChan(txtutil.Win1251ToUTF8(f), ';', false, 1)
-		var err error
-		for v := range vCh {
-			if v.Error != nil {
-				continue
-			}
-			switch i {
-			case 0:
-				err = c.parseRecordApt(v.Record)
-			case 1:
-				err = c.parseRecordSp(v.Record)
-			case 2:
-				err = c.parseRecordOst(v.Record)
-			}
-			if err != nil {
-				return err
-			}
+		err := c.parseRecords(s, vCh, parse)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -116,10 +133,10 @@ func (c *cmdStl) transformCSVs() error {
 func (c *cmdStl) parseRecordApt(r []string) error {
 	csvLen := 2
 	if len(r) < csvLen {
-		return fmt.Errorf("invalid csv: got %d, want %d", len(r), csvLen)
+		return rejectRow(reasonShort)
 	}
 
-	s := shop{
+	s := model.Shop{
 		ID:   strings.TrimSpace(r[0]),
 		Name: strings.TrimSpace(r[1]),
 		Head: stlHead,
@@ -138,10 +155,10 @@ func (c *cmdStl) parseRecordApt(r []string) error {
 func (c *cmdStl) parseRecordSp(r []string) error {
 	csvLen := 4
 	if len(r) < csvLen {
-		return fmt.Errorf("invalid csv: got %d, want %d", len(r), csvLen)
+		return rejectRow(reasonShort)
 	}
 
-	d := drug{
+	d := model.Product{
 		ID:   strings.TrimSpace(r[0]),
 		Name: fmt.Sprintf("%s %s %s", strings.TrimSpace(r[1]), strings.TrimSpace(r[2]), strings.TrimSpace(r[3])),
 	}
@@ -154,75 +171,51 @@ func (c *cmdStl) parseRecordSp(r []string) error {
 func (c *cmdStl) parseRecordOst(r []string) error {
 	csvLen := 4
 	if len(r) < csvLen {
-		return fmt.Errorf("invalid csv: got %d, want %d", len(r), csvLen)
+		return rejectRow(reasonShort)
 	}
 
 	s, ok := c.mapShop[strings.TrimSpace(r[0])]
 	if !ok {
-		return fmt.Errorf("shop not found %s", r[0])
+		return unknownRef(refShop, strings.TrimSpace(r[0]))
 	}
 
 	d, ok := c.mapDrug[strings.TrimSpace(r[1])]
 	if !ok {
-		return fmt.Errorf("drug not found %s", r[1])
+		return unknownRef(refDrug, strings.TrimSpace(r[1]))
 	}
 
 	quant, err := strconv.ParseFloat(strings.TrimSpace(r[2]), 64)
 	if err != nil {
-		return err
+		return rejectRow(reasonNumber)
 	}
 	price, err := strconv.ParseFloat(strings.TrimSpace(r[3]), 64)
 	if err != nil {
-		return err
+		return rejectRow(reasonNumber)
 	}
 
-	p := prop{
-		ID:    d.ID,
-		Name:  d.Name,
-		Quant: quant,
-		Price: price,
+	p := model.Stock{
+		Product: d,
+		Quant:   quant,
+		Price:   price,
 	}
 
 	c.mapProp[s.ID] = append(c.mapProp[s.ID], p)
 	return nil
 }
 
-func (c *cmdStl) uploadGzipJSONs() error {
-	b := new(bytes.Buffer)
-	w := gzip.NewWriter(b)
-
-	var n int
-	var err error
-	for k, v := range c.mapProp {
-		p := price{
-			Meta: c.mapShop[k],
-			Data: v,
-		}
-
-		b.Reset()
-		w.Reset(b)
-
-		err = json.NewEncoder(w).Encode(p)
-		if err != nil {
-			return err
-		}
-
-		err = w.Close()
-		if err != nil {
-			return err
-		}
+func (c *cmdStl) uploadGzipJSONs(ctx context.Context) error {
+	keys := make([]string, 0, len(c.mapProp))
+	for k := range c.mapProp {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 
-		n++
-		err = c.pushGzipV2(b, fmt.Sprintf("%s (%d) %s %d", c.name, n, p.Meta.ID, len(p.Data)))
-		if err != nil {
-			return err
+	return c.uploadAll(ctx, keys, func(ctx context.Context, k string, n int) error {
+		l := &model.StockList{
+			Shop:  c.mapShop[k],
+			Items: c.mapProp[k],
 		}
 
-		//err = ioutil.WriteFile(k+".gz", b.Bytes(), 0666)
-		//i/f err != nil {
-		//	return err
-		//}
-	}
-
-	return nil
+		return c.pushStock(ctx, l, k, n)
+	})
 }
@@ -1,8 +1,8 @@
 package run
 
 import (
+	"context"
 	"flag"
-	"log"
 )
 
 type cmdTst struct {
@@ -16,10 +16,10 @@ func NewCmdTst() *cmdTst {
 }
 
 func (c *cmdTst) setFlags(f *flag.FlagSet) {
-	log.Println("test setFlag()")
+	logger.Info("test setFlag()")
 }
 
-func (c *cmdTst) exec() error {
-	log.Println("test exec()")
+func (c *cmdTst) exec(ctx context.Context) error {
+	c.log.InfoContext(ctx, "test exec()")
 	return nil
 }
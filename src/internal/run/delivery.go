@@ -0,0 +1,167 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"internal/i18n"
+)
+
+const maxReportNames = 20
+
+// delivery tracks which payloads (shops) were and weren't delivered
+type delivery struct {
+	sync.Mutex
+	want map[string]bool
+	done map[string]bool
+	same map[string]bool // unchanged since last delivery (see -delta)
+	size map[string]int  // items of payloads, see count
+	p    *i18n.Printer   // language of String
+}
+
+func (d *delivery) init() {
+	if d.want == nil {
+		d.want = make(map[string]bool)
+		d.done = make(map[string]bool)
+		d.same = make(map[string]bool)
+		d.size = make(map[string]int)
+	}
+}
+
+func (d *delivery) expect(name ...string) {
+	d.Lock()
+	defer d.Unlock()
+	d.init()
+	for i := range name {
+		d.want[name[i]] = true
+	}
+}
+
+func (d *delivery) deliver(name string) {
+	d.Lock()
+	defer d.Unlock()
+	d.init()
+	d.want[name] = true
+	d.done[name] = true
+}
+
+// skip marks expected payload as not needed
+func (d *delivery) skip(name string) {
+	d.Lock()
+	defer d.Unlock()
+	d.init()
+	delete(d.want, name)
+	d.same[name] = true
+}
+
+// count records number of items (e.g. stock rows) of payload
+func (d *delivery) count(name string, n int) {
+	d.Lock()
+	defer d.Unlock()
+	d.init()
+	d.size[name] = n
+}
+
+// totals returns number of shops delivered or unchanged and their items
+func (d *delivery) totals() (int, int) {
+	d.Lock()
+	defer d.Unlock()
+
+	var shops, items int
+	for k := range d.size {
+		if d.done[k] || d.same[k] {
+			shops++
+			items += d.size[k]
+		}
+	}
+	for k := range d.done {
+		if _, ok := d.size[k]; !ok {
+			shops++ // payload without items, e.g. a file
+		}
+	}
+	return shops, items
+}
+
+func (d *delivery) delivered(name string) bool {
+	d.Lock()
+	defer d.Unlock()
+	return d.done[name]
+}
+
+func (d *delivery) empty() bool {
+	d.Lock()
+	defer d.Unlock()
+	return len(d.want) == 0 && len(d.same) == 0
+}
+
+// String returns report like "delivered 2 of 3: a, b; not delivered: c; unchanged 5"
+func (d *delivery) String() string {
+	d.Lock()
+	defer d.Unlock()
+
+	var done, fail []string
+	for k := range d.want {
+		if d.done[k] {
+			done = append(done, k)
+		} else {
+			fail = append(fail, k)
+		}
+	}
+
+	s := d.p.Sprintf("delivered %d of %d", len(done), len(d.want))
+	if len(done) > 0 {
+		s += ": " + joinNames(d.p, done)
+	}
+	if len(fail) > 0 {
+		s += "; " + d.p.Sprintf("not delivered") + ": " + joinNames(d.p, fail)
+	}
+	if len(d.same) > 0 {
+		s += "; " + d.p.Sprintf("unchanged %d", len(d.same))
+	}
+	return s
+}
+
+func joinNames(p *i18n.Printer, l []string) string {
+	sort.Strings(l)
+	if len(l) > maxReportNames {
+		return p.Sprintf("%s and %d more", strings.Join(l[:maxReportNames], ", "), len(l)-maxReportNames)
+	}
+	return strings.Join(l, ", ")
+}
+
+// expect registers payloads which are going to be pushed by keys
+func (c *cmdBase) expect(key ...string) {
+	for i := range key {
+		c.sent.expect(c.payloadName(key[i]))
+	}
+}
+
+func (c *cmdBase) payloadName(key string) string {
+	return fmt.Sprintf("%s_%s", c.name, key)
+}
+
+// deliveryError fails run because of transport (pull or push), files of
+// such run are left for the next one, see failSources
+type deliveryError struct {
+	error
+}
+
+func (e deliveryError) Unwrap() error {
+	return e.error
+}
+
+// failedDelivery marks err as deliveryError, nil stays nil
+func failedDelivery(err error) error {
+	if err == nil || isDelivery(err) {
+		return err
+	}
+	return deliveryError{err}
+}
+
+func isDelivery(err error) bool {
+	var e deliveryError
+	return errors.As(err, &e)
+}
@@ -0,0 +1,61 @@
+package run
+
+import (
+	"fmt"
+	"strings"
+
+	"internal/model"
+	"internal/store/snapshot"
+)
+
+// Modes of -delta
+const (
+	deltaChanged = "changed" // push full stock of changed shops only
+	deltaDiff    = "diff"    // push priceDiff of changed shops, full stock of new ones
+)
+
+// openSnapshots opens snapshot store from -snapshot if -delta is defined,
+// in dry-run mode snapshots are read but never updated
+func (c *cmdBase) openSnapshots() error {
+	switch c.flagDelta {
+	case "":
+		return nil
+	case deltaChanged, deltaDiff:
+	default:
+		return fmt.Errorf("unknown delta mode '%s'", c.flagDelta)
+	}
+
+	// priceDiff is a payload of API v2 whatever API of command is
+	if c.flagDelta == deltaDiff {
+		dst := []string(c.flagDst)
+		if len(dst) == 0 {
+			dst = []string{c.flagSRV}
+		}
+		for _, v := range dst {
+			if strings.HasPrefix(strings.ToLower(v), "v1+") {
+				return fmt.Errorf("delta diff is pushed by API v2, destination is v1: %s", redactAddrs(v))
+			}
+		}
+	}
+
+	var err error
+	c.snapshots, err = snapshot.Open(c.flagSnap)
+	return err
+}
+
+// lastStock returns snapshot of shop delivered last time or nil
+func (c *cmdBase) lastStock(key string) (*snapshot.Snapshot, error) {
+	if c.snapshots == nil {
+		return nil, nil
+	}
+	return c.snapshots.Get(c.runName, key)
+}
+
+// saveStock replaces snapshot of shop if its payload is delivered to all
+// destinations, a payload kept in outbox does not count
+func (c *cmdBase) saveStock(key string, l *model.StockList) error {
+	if c.snapshots == nil || c.dryRun || !c.sent.delivered(c.payloadName(key)) {
+		return nil
+	}
+	return c.snapshots.Put(c.runName, key, l)
+}
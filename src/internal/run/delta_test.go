@@ -0,0 +1,74 @@
+package run
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"internal/model"
+)
+
+// newDeltaRun returns a55 run which pushes to dir dst with -delta mode
+func newDeltaRun(t *testing.T, mode, snap string, dst ...string) *cmdA55 {
+	c := NewCmdA55()
+	c.flagDelta = mode
+	c.flagSnap = snap
+	c.flagDst = dst
+	c.runName = c.name
+	c.log = logger
+	c.sent.p = printerFrom(context.Background())
+	return c
+}
+
+func TestDeltaSnapshotKey(t *testing.T) {
+	snap, dst := t.TempDir(), "dir://"+t.TempDir()
+	l := &model.StockList{
+		Shop:  model.Shop{Name: "Аптека 1"},
+		Items: []model.Stock{{Product: model.Product{ID: "10", Name: "Аспирин"}, Quant: 5, Price: 12.5}},
+	}
+
+	for i, v := range []struct {
+		keys []string
+		same string
+	}{
+		{[]string{"ost_1", "ost_2"}, ""},
+		{[]string{"ost_2", "ost_1"}, "unchanged 2"}, // order of files does not matter
+		{[]string{"ost_3"}, ""},
+	} {
+		c := newDeltaRun(t, deltaChanged, snap, dst)
+		err := c.openSnapshots()
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.lists = []*model.StockList{l, l}[:len(v.keys)]
+		c.keys = v.keys
+
+		err = c.uploadGzipJSONs(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := c.sent.String(); v.same != "" && !strings.Contains(s, v.same) || v.same == "" && strings.Contains(s, "unchanged") {
+			t.Errorf("run %d: %s", i+1, s)
+		}
+	}
+}
+
+func TestDeltaDiffV1(t *testing.T) {
+	for _, v := range []struct {
+		dst []string
+		srv string
+		err bool
+	}{
+		{[]string{"https://skynet.example.com"}, "", false},
+		{[]string{"v2+https://skynet.example.com"}, "", false},
+		{[]string{"dir:///tmp", "V1+https://skynet.example.com"}, "", true},
+		{nil, "v1+https://skynet.example.com", true},
+	} {
+		c := newDeltaRun(t, deltaDiff, t.TempDir(), v.dst...)
+		c.flagSRV = v.srv
+		err := c.openSnapshots()
+		if v.err != (err != nil) {
+			t.Errorf("%v %s: error %v", v.dst, v.srv, err)
+		}
+	}
+}
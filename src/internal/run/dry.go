@@ -0,0 +1,137 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"internal/net/sink"
+	"internal/net/source"
+)
+
+type ctxKey int
+
+const (
+	ctxDryRun ctxKey = iota
+	ctxLang
+)
+
+// WithDryRun returns context which turns on dry-run mode for commands:
+// no ping, no deletes on sources, payloads and summary are written to dir
+func WithDryRun(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, ctxDryRun, dir)
+}
+
+func dryRunFrom(ctx context.Context) (string, bool) {
+	dir, ok := ctx.Value(ctxDryRun).(string)
+	return dir, ok
+}
+
+// Data structs
+
+type dryPayload struct {
+	Name  string `json:"name"`
+	File  string `json:"file"`
+	Desc  string `json:"desc"`
+	Bytes int    `json:"bytes"`
+}
+
+type dryDelete struct {
+	Origin string   `json:"origin"`
+	Files  []string `json:"files"`
+}
+
+type drySummary struct {
+	sync.Mutex `json:"-"`
+
+	Command  string       `json:"command"`
+	Time     time.Time    `json:"time"`
+	Dst      []string     `json:"dst"`
+	Payloads []dryPayload `json:"payloads"`
+	Deletes  []dryDelete  `json:"deletes,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+func (s *drySummary) addPayload(name, desc string, n int) {
+	s.Lock()
+	defer s.Unlock()
+	s.Payloads = append(s.Payloads, dryPayload{
+		Name:  name,
+		File:  sink.FileName(name),
+		Desc:  desc,
+		Bytes: n,
+	})
+}
+
+func (s *drySummary) addDst(l []sink.Sinker) {
+	s.Lock()
+	defer s.Unlock()
+	for i := range l {
+		s.Dst = append(s.Dst, l[i].String())
+	}
+}
+
+func (s *drySummary) addDelete(origin string, name ...string) {
+	s.Lock()
+	defer s.Unlock()
+	s.Deletes = append(s.Deletes, dryDelete{Origin: origin, Files: name})
+}
+
+// writeFile writes summary to dir and returns its path
+func (s *drySummary) writeFile(dir string) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return "", err
+	}
+
+	f := filepath.Join(dir, fileName(s.Command)+"_summary.json")
+	return f, ioutil.WriteFile(f, b, 0644)
+}
+
+// drySource never deletes anything on the wrapped source
+type drySource struct {
+	src source.Sourcer
+	sum *drySummary
+	org string
+}
+
+func newDrySource(src source.Sourcer, addr string, sum *drySummary) *drySource {
+	org := addr
+	if u, err := url.Parse(addr); err == nil {
+		u.User = nil
+		org = u.String()
+	}
+	// origin goes to log tail of notifications, see redactAddrs
+	return &drySource{src: src, sum: sum, org: redactAddrs(org)}
+}
+
+func (s *drySource) Files(ctx context.Context, nameOK func(string) bool, _ bool) <-chan struct {
+	File  source.Filer
+	Error error
+} {
+	return s.src.Files(ctx, nameOK, false)
+}
+
+func (s *drySource) Delete(ctx context.Context, name ...string) error {
+	logger.InfoContext(ctx, "dry-run: skip delete", "stage", stageDelete, "origin", s.org, "files", name)
+	s.sum.addDelete(s.org, name...)
+	return nil
+}
+
+func (s *drySource) Fail(ctx context.Context) error {
+	logger.InfoContext(ctx, "dry-run: skip fail", "stage", stageDelete, "origin", s.org)
+	return nil
+}
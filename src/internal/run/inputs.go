@@ -0,0 +1,205 @@
+package run
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"internal/logs"
+	"internal/net/source"
+)
+
+// keptInputs is number of the last closed source files which are kept open,
+// so the offending one can be attached, older ones are freed
+const keptInputs = 4
+
+// inputs remembers source files of run, so the offending one can be attached
+// to notification, see offending
+type inputs struct {
+	mu   sync.Mutex
+	l    []*inputFile
+	kept []*inputFile // closed but not freed, the last keptInputs
+}
+
+// inputFile is a source file which is kept open after Close unless it is
+// too large to be attached or older than the last keptInputs files
+type inputFile struct {
+	source.Filer
+	in     *inputs
+	closed bool // guarded by inputs.mu
+	freed  bool // guarded by inputs.mu
+}
+
+func (f *inputFile) Close() error {
+	in := f.in
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	if f.Size() > maxAttachment {
+		return f.free()
+	}
+
+	in.kept = append(in.kept, f)
+	if len(in.kept) <= keptInputs {
+		return nil
+	}
+	old := in.kept[0]
+	in.kept = in.kept[1:]
+	return old.free()
+}
+
+// free closes file, inputs.mu is held
+func (f *inputFile) free() error {
+	if f.freed {
+		return nil
+	}
+	f.freed = true
+	return f.Filer.Close()
+}
+
+func (in *inputs) add(f source.Filer) source.Filer {
+	v := &inputFile{Filer: f, in: in}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.l = append(in.l, v)
+
+	return v
+}
+
+// offending returns the last file whose name is in error, the last file
+// if there is no such one or nil
+func (in *inputs) offending(err error) *inputFile {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if len(in.l) == 0 {
+		return nil
+	}
+
+	for i := len(in.l) - 1; i >= 0; i-- {
+		if err != nil && strings.Contains(err.Error(), in.l[i].Name()) {
+			return in.l[i]
+		}
+	}
+	return in.l[len(in.l)-1]
+}
+
+// find returns the last file with name or nil
+func (in *inputs) find(name string) *inputFile {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	for i := len(in.l) - 1; i >= 0; i-- {
+		if in.l[i].Name() == name {
+			return in.l[i]
+		}
+	}
+	return nil
+}
+
+// data returns content of file, nil if it is too large or freed already
+func (f *inputFile) data() []byte {
+	f.in.mu.Lock()
+	defer f.in.mu.Unlock()
+
+	if f.freed || f.Size() > maxAttachment {
+		return nil
+	}
+
+	b, err := ioutil.ReadAll(io.NewSectionReader(f, 0, f.Size()))
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// close frees files at the end of run
+func (in *inputs) close() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	for i := range in.l {
+		_ = in.l[i].free()
+	}
+	in.l, in.kept = nil, nil
+}
+
+// inputSource records files of source in inputs and logs pulls and deletes
+type inputSource struct {
+	source.Sourcer
+	in  *inputs
+	log *slog.Logger
+}
+
+func (s *inputSource) Files(ctx context.Context, nameOK func(string) bool, cleanup bool) <-chan struct {
+	File  source.Filer
+	Error error
+} {
+	pipe := make(chan struct {
+		File  source.Filer
+		Error error
+	})
+	go func() {
+		defer func() { close(pipe) }()
+		for v := range s.Sourcer.Files(logs.With(ctx, "stage", stagePull), nameOK, cleanup) {
+			if v.File != nil {
+				s.log.Info("file", "stage", stagePull, "file", v.File.Name(), "bytes", v.File.Size(), "origin", v.File.Origin().Scheme+"://"+v.File.Origin().Host)
+				v.File = s.in.add(v.File)
+			}
+			v.Error = failedDelivery(v.Error)
+			select {
+			case pipe <- v:
+			case <-ctx.Done():
+				if v.File != nil {
+					_ = v.File.Close()
+				}
+				return
+			}
+		}
+	}()
+
+	return pipe
+}
+
+func (s *inputSource) Delete(ctx context.Context, name ...string) error {
+	if len(name) == 0 {
+		return nil
+	}
+
+	t := time.Now()
+	err := s.Sourcer.Delete(logs.With(ctx, "stage", stageDelete), name...)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("delete", "stage", stageDelete, "files", len(name), "duration", time.Since(t))
+	return nil
+}
+
+// Fail puts aside files of failed run if the source can do it, see
+// source.Failer
+func (s *inputSource) Fail(ctx context.Context) error {
+	i, ok := s.Sourcer.(source.Failer)
+	if !ok {
+		return nil
+	}
+
+	t := time.Now()
+	err := i.Fail(logs.With(ctx, "stage", stageDelete))
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("fail", "stage", stageDelete, "duration", time.Since(t))
+	return nil
+}
@@ -0,0 +1,24 @@
+package run
+
+import (
+	"context"
+
+	"internal/i18n"
+
+	"golang.org/x/text/language"
+)
+
+// WithLang returns context which makes commands notify and report in
+// language, see i18n.Parse
+func WithLang(ctx context.Context, tag language.Tag) context.Context {
+	return context.WithValue(ctx, ctxLang, tag)
+}
+
+// printerFrom returns printer of language of ctx, English by default
+func printerFrom(ctx context.Context) *i18n.Printer {
+	tag, ok := ctx.Value(ctxLang).(language.Tag)
+	if !ok {
+		tag = language.English
+	}
+	return i18n.NewPrinter(tag)
+}
@@ -0,0 +1,106 @@
+package run
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+
+	"internal/net/source"
+	"internal/store/ledger"
+)
+
+// openLedger opens ledger of processed files from -ledger
+func (c *cmdBase) openLedger() error {
+	if c.flagLedger == "" {
+		return nil
+	}
+
+	var err error
+	c.ledger, err = ledger.Open(c.flagLedger, c.flagLedgK)
+	return err
+}
+
+// ledgerEntry returns ledger entry of file with hash of its content
+func (c *cmdBase) ledgerEntry(f source.Filer) (ledger.Entry, error) {
+	h := sha256.New()
+	_, err := io.Copy(h, io.NewSectionReader(f, 0, f.Size()))
+	if err != nil {
+		return ledger.Entry{}, err
+	}
+
+	return ledger.Entry{
+		Command: c.runName,
+		Origin:  f.Origin().String(),
+		Name:    f.Name(),
+		Size:    f.Size(),
+		MTime:   f.Time(),
+		Hash:    hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// processed reports whether file was processed by command before, a new
+// file is recorded in ledger when the run succeeds
+func (c *cmdBase) processed(f source.Filer) (bool, error) {
+	if c.ledger == nil {
+		return false, nil
+	}
+
+	e, err := c.ledgerEntry(f)
+	if err != nil {
+		return false, err
+	}
+
+	ok := c.ledger.Has(e)
+	if ok {
+		c.log.Info("processed before", "stage", stagePull, "origin", e.Origin, "file", e.Name)
+	}
+
+	c.mu.Lock()
+	if ok {
+		c.seen = append(c.seen, e)
+	} else {
+		c.ingested = append(c.ingested, e)
+	}
+	c.mu.Unlock()
+	return ok, nil
+}
+
+// allProcessed reports whether every file of set was processed before,
+// a set with at least one new file is processed entirely
+func (c *cmdBase) allProcessed(files ...source.Filer) (bool, error) {
+	all := len(files) > 0
+	for i := range files {
+		ok, err := c.processed(files[i])
+		if err != nil {
+			return false, err
+		}
+		all = all && ok
+	}
+	return all, nil
+}
+
+// commitLedger records files of successful run and keeps entries of files
+// which are processed before, in dry-run mode nothing is recorded
+func (c *cmdBase) commitLedger() error {
+	if c.ledger == nil || c.dryRun {
+		return nil
+	}
+	if len(c.seen) > 0 {
+		err := c.ledger.Touch(c.seen...)
+		if err != nil {
+			return err
+		}
+	}
+	if len(c.ingested) == 0 {
+		return nil
+	}
+	return c.ledger.Add(c.ingested...)
+}
+
+func filesOf(m map[string]source.Filer) []source.Filer {
+	l := make([]source.Filer, 0, len(m))
+	for _, v := range m {
+		l = append(l, v)
+	}
+	return l
+}
@@ -0,0 +1,33 @@
+package run
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"internal/logs"
+)
+
+// Stages of run, lines of logs have attribute stage
+const (
+	stagePing      = "ping"
+	stagePull      = "pull"
+	stageTransform = "transform"
+	stagePush      = "push"
+	stageDelete    = "delete"
+)
+
+// logger is for lines without command, lines of command are logged by
+// cmdBase.log with run ID and command
+var logger = logs.For("run")
+
+// newRunID returns random ID of run, it falls back to time if there is no
+// randomness
+func newRunID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,47 @@
+package run
+
+import (
+	"strings"
+	"sync"
+)
+
+// logTail keeps the last n lines written to log for notifications
+type logTail struct {
+	mu    sync.Mutex
+	n     int
+	lines []string
+	part  string // line without newline yet
+}
+
+func newLogTail(n int) *logTail {
+	return &logTail{n: n, lines: make([]string, 0, n)}
+}
+
+func (t *logTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	l := strings.Split(t.part+string(p), "\n")
+	t.part = l[len(l)-1]
+	for _, v := range l[:len(l)-1] {
+		if len(t.lines) == t.n {
+			copy(t.lines, t.lines[1:])
+			t.lines = t.lines[:t.n-1]
+		}
+		t.lines = append(t.lines, v)
+	}
+
+	return len(p), nil
+}
+
+// Lines returns copy of the last lines, nil for nil tail
+func (t *logTail) Lines() []string {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]string(nil), t.lines...)
+}
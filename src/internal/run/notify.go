@@ -0,0 +1,185 @@
+package run
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"internal/net/notify"
+	"internal/store/runstate"
+)
+
+// openNotifiers makes notifiers of -notify and -mgn, -mfm and -mto are
+// defaults of mail notifiers
+func (c *cmdBase) openNotifiers() error {
+	addrs := []string(c.flagNotify)
+	if c.flagMGn != "" {
+		addrs = append(addrs, c.flagMGn)
+	}
+
+	var to []string
+	for _, v := range strings.Split(c.flagMTo, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			to = append(to, v)
+		}
+	}
+
+	o := notify.Options{
+		From:    c.flagMFm,
+		To:      to,
+		Timeout: c.timeout,
+		Retry:   c.retryPolicy(),
+	}
+
+	c.notifiers = make([]notify.Notifier, 0, len(addrs))
+	for i := range addrs {
+		n, err := notify.New(addrs[i], o)
+		if err != nil {
+			return err
+		}
+		c.notifiers = append(c.notifiers, n)
+	}
+
+	return nil
+}
+
+// notifyAll sends message to every notifier, a failed notifier does not
+// stop the rest, the run may be canceled already
+func (c *cmdBase) notifyAll(m notify.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*c.timeout)
+	defer cancel()
+
+	var errs []string
+	for _, n := range c.notifiers {
+		t := time.Now()
+		err := n.Notify(ctx, m)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", n, err))
+			continue
+		}
+		c.log.Info("notify", "notifier", n.String(), "duration", time.Since(t))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("notify: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// reDigits makes errors which differ in numbers only (e.g. counts, times)
+// the same, see fingerprint
+var reDigits = regexp.MustCompile(`[0-9]+`)
+
+func fingerprint(err error) string {
+	h := sha256.Sum256([]byte(reDigits.ReplaceAllString(err.Error(), "#")))
+	return hex.EncodeToString(h[:8])
+}
+
+// openStates opens states of runs from -state, tools are not tracked
+func (c *cmdBase) openStates() error {
+	if c.flagState == "" || c.untracked {
+		return nil
+	}
+
+	var err error
+	c.states, err = runstate.Open(c.flagState)
+	return err
+}
+
+// sendError notifies about failed run started at t, the same error (see
+// fingerprint of root, err without delivery and cancel) is notified once
+// per -repeat, the rest are counted
+func (c *cmdBase) sendError(err, root error, t time.Time) error {
+	var st *runstate.State
+	if c.states != nil {
+		var serr error
+		st, serr = c.states.Get(c.runName)
+		if serr != nil {
+			c.log.Error("state failed", "err", serr)
+			st = nil
+		}
+	}
+
+	send := true
+	if st != nil {
+		if !st.Failing {
+			st.Failing, st.Since, st.Failures, st.Suppressed = true, t, 0, 0
+			st.Notified = time.Time{}
+		}
+		st.Failures++
+		st.LastRun = t
+		st.Error = err.Error()
+
+		fp := fingerprint(root)
+		send = fp != st.Fingerprint || c.flagRepeat <= 0 || t.Sub(st.Notified) >= c.flagRepeat
+		st.Fingerprint = fp
+	}
+
+	var m notify.Message
+	if send {
+		m = c.newNotice(noticeError, c.p.Sprintf("ERROR [%s]", c.runName), err, st).message()
+		err = c.notifyAll(m)
+	} else {
+		c.log.Info("notify: repeat suppressed", "notified", st.Notified, "suppressed", st.Suppressed+1)
+		err = nil
+	}
+
+	if st == nil {
+		return err
+	}
+
+	// a failed notification is repeated next time
+	switch {
+	case !send:
+		st.Suppressed++
+	case err == nil:
+		st.Notified, st.Suppressed = m.Time, 0
+	}
+
+	if serr := c.states.Put(st); serr != nil && err == nil {
+		err = serr
+	}
+	return err
+}
+
+// sendRecovery records successful run started at t, the first success after
+// failures is notified
+func (c *cmdBase) sendRecovery(t time.Time) error {
+	if c.states == nil {
+		return nil
+	}
+
+	st, err := c.states.Get(c.runName)
+	if err != nil {
+		return err
+	}
+
+	st.LastRun, st.LastOK = t, t
+	st.Shops, st.Items = c.sent.totals()
+
+	if st.Failing {
+		n := c.newNotice(noticeRecovery, c.p.Sprintf("RECOVERED [%s]", c.runName), nil, st)
+		n.Text = c.p.Sprintf("recovered after %d failed runs since %s", st.Failures, c.p.DateTime(st.Since)) +
+			"\n\n" + c.p.Sprintf("last error: %s", st.Error)
+		if n.Sent != "" {
+			n.Text += "\n\n" + n.Sent
+		}
+
+		// recovery is notified again next time unless it is sent
+		err = c.notifyAll(n.message())
+		if err != nil {
+			_ = c.states.Put(st)
+			return err
+		}
+	}
+
+	st.Failing, st.Failures, st.Suppressed = false, 0, 0
+	st.Since, st.Notified = time.Time{}, time.Time{}
+	st.Error, st.Fingerprint = "", ""
+
+	return c.states.Put(st)
+}
@@ -0,0 +1,157 @@
+package run
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"internal/store/runstate"
+
+	"github.com/google/subcommands"
+)
+
+// hookStandIn records notifications posted to webhook, it fails while down
+type hookStandIn struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	down bool
+	msgs []map[string]string
+}
+
+func newHookStandIn(t *testing.T) *hookStandIn {
+	h := &hookStandIn{}
+	h.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.down {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		m := make(map[string]string)
+		_ = json.NewDecoder(r.Body).Decode(&m)
+		h.msgs = append(h.msgs, m)
+	}))
+	t.Cleanup(h.Close)
+	return h
+}
+
+// take returns notifications received since the last call
+func (h *hookStandIn) take() []map[string]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l := h.msgs
+	h.msgs = nil
+	return l
+}
+
+func (h *hookStandIn) setDown(down bool) {
+	h.mu.Lock()
+	h.down = down
+	h.mu.Unlock()
+}
+
+func TestNotifyRepeat(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		msg string // error of skynet, empty is ok
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if msg != "" {
+			http.Error(w, msg, http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	hook := newHookStandIn(t)
+	state := t.TempDir()
+
+	for i, v := range []struct {
+		msg      string
+		repeat   string
+		hookDown bool
+		status   subcommands.ExitStatus
+		errors   int // error notifications
+		recovery bool
+	}{
+		{"down", "1h", false, subcommands.ExitFailure, 1, false},
+		{"down", "1h", false, subcommands.ExitFailure, 0, false}, // repeat suppressed
+		{"down for 15 min", "1h", false, subcommands.ExitFailure, 1, false},
+		{"down for 20 min", "1h", false, subcommands.ExitFailure, 0, false}, // numbers do not matter
+		{"", "1h", false, subcommands.ExitSuccess, 0, true},
+		{"", "1h", false, subcommands.ExitSuccess, 0, false},
+		{"down", "1h", true, subcommands.ExitFailure, 0, false},  // notifier fails
+		{"down", "1h", false, subcommands.ExitFailure, 1, false}, // so it is repeated
+		{"down", "0", false, subcommands.ExitFailure, 1, false},
+	} {
+		mu.Lock()
+		msg = v.msg
+		mu.Unlock()
+		hook.setDown(v.hookDown)
+
+		s := execute(t, NewCmdTst(), "-srv", srv.URL, "-state", state, "-notify", "hook+"+hook.URL, "-repeat", v.repeat)
+		if s != v.status {
+			t.Errorf("run %d: status %v", i+1, s)
+		}
+
+		errors, recovery := 0, false
+		for _, m := range hook.take() {
+			if !strings.Contains(m["subject"], "[test]") {
+				t.Errorf("run %d: subject %s", i+1, m["subject"])
+			}
+			if m["error"] != "" {
+				errors++
+				continue
+			}
+			recovery = true
+			if !strings.Contains(m["text"], "4") || !strings.Contains(m["text"], "down for 20 min") {
+				t.Errorf("run %d: recovery %s", i+1, m["text"])
+			}
+		}
+		if errors != v.errors || recovery != v.recovery {
+			t.Errorf("run %d: %d errors, recovery %v", i+1, errors, recovery)
+		}
+	}
+
+	st, err := runstate.Open(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := st.Get("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Failing || v.Failures != 3 || v.Suppressed != 0 || v.LastOK.IsZero() {
+		t.Errorf("state %+v", v)
+	}
+}
+
+func TestNotifySuppressedCount(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	hook := newHookStandIn(t)
+	state := t.TempDir()
+	for i := 0; i < 3; i++ {
+		execute(t, NewCmdTst(), "-srv", srv.URL, "-state", state, "-notify", "hook+"+hook.URL)
+	}
+
+	st, err := runstate.Open(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := st.Get("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(hook.take()); n != 1 || v.Failures != 3 || v.Suppressed != 2 || v.Notified.IsZero() {
+		t.Errorf("%d notifications, state %+v", n, v)
+	}
+}
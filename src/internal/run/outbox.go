@@ -0,0 +1,154 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"internal/logs"
+	"internal/net/sink"
+	"internal/store/outbox"
+)
+
+// openOutbox opens outbox from -outbox, it is always off in dry-run mode
+func (c *cmdBase) openOutbox() error {
+	if c.flagOutbox == "" || c.dryRun {
+		return nil
+	}
+
+	var err error
+	c.outbox, err = outbox.Open(c.flagOutbox)
+	return err
+}
+
+// pushViaOutbox keeps payload in outbox until destination returns 2xx,
+// it fails only if payload can not be saved
+func (c *cmdBase) pushViaOutbox(ctx context.Context, b []byte, name, s string, v apiV, dst string, snk sink.Sinker) (bool, error) {
+	e, err := c.outbox.Put(
+		outbox.Entry{
+			Command: c.runName,
+			Name:    name,
+			Desc:    s,
+			Dst:     dst,
+			API:     int(v),
+			Key:     c.flagKey,
+			Tag:     c.flagTag,
+		},
+		bytes.NewReader(b),
+	)
+	if err != nil {
+		return false, fmt.Errorf("%v: %s", err, s)
+	}
+
+	if err = c.sinkDown(snk); err != nil {
+		c.keep(s, err)
+		return false, nil
+	}
+
+	t := time.Now()
+	err = snk.Push(logs.With(context.WithoutCancel(ctx), "stage", stagePush), bytes.NewReader(b), name)
+	if err != nil {
+		c.setSinkDown(snk, err)
+		c.keep(s, err)
+		return false, nil
+	}
+
+	c.log.Info("push", "stage", stagePush, "payload", s, "bytes", len(b), "duration", time.Since(t), "dst", snk.String())
+	return true, c.outbox.Remove(e)
+}
+
+// flushOutbox pushes entries of command (all if cmd is empty) kept in outbox
+func (c *cmdBase) flushOutbox(ctx context.Context, cmd string) error {
+	if c.outbox == nil {
+		return nil
+	}
+
+	l, err := c.outbox.List(cmd)
+	if err != nil {
+		return err
+	}
+
+	for i := range l {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		err = c.flushEntry(ctx, l[i])
+		if err != nil {
+			c.keep(l[i].Desc, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *cmdBase) flushEntry(ctx context.Context, e outbox.Entry) error {
+	s, err := sink.New(e.Dst, sink.Options{
+		Key:     e.Key,
+		Tag:     e.Tag,
+		API:     e.API,
+		Timeout: c.timeout,
+		Retry:   c.retryPolicy(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = c.sinkDown(s); err != nil {
+		return err
+	}
+
+	r, err := c.outbox.Open(e)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadAll(r)
+	_ = r.Close()
+	if err != nil {
+		return err
+	}
+
+	t := time.Now()
+	err = s.Push(logs.With(context.WithoutCancel(ctx), "stage", stagePush), bytes.NewReader(b), e.Name)
+	if err != nil {
+		c.setSinkDown(s, err)
+		return err
+	}
+
+	c.log.Info("flush", "stage", stagePush, "payload", e.Desc, "bytes", len(b), "duration", time.Since(t), "dst", s.String())
+	return c.outbox.Remove(e)
+}
+
+// sinkDown returns error of last failed push to s, pushes to s are skipped
+// until the next run
+func (c *cmdBase) sinkDown(s sink.Sinker) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.down[s.String()]
+}
+
+func (c *cmdBase) setSinkDown(s sink.Sinker, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.down[s.String()] = err
+}
+
+func (c *cmdBase) keep(s string, err error) {
+	c.log.Warn("kept in outbox", "stage", stagePush, "payload", s, "err", err)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.kept++
+	c.keptErr = err
+}
+
+// outboxError reports payloads which are left in outbox
+func (c *cmdBase) outboxError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.kept == 0 {
+		return nil
+	}
+	return failedDelivery(fmt.Errorf("outbox: %d payload(s) kept in %s: %v", c.kept, c.outbox.Dir(), c.keptErr))
+}
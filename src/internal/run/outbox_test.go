@@ -0,0 +1,76 @@
+package run
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"internal/store/outbox"
+
+	"github.com/google/subcommands"
+)
+
+// execute runs command c with args, the run keeps its files in temp dirs
+func execute(t *testing.T, c subcommands.Command, args ...string) subcommands.ExitStatus {
+	f := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	c.SetFlags(f)
+
+	tmp := t.TempDir()
+	defaults := []string{
+		"-report", filepath.Join(tmp, "report"),
+		"-snapshot", filepath.Join(tmp, "snapshot"),
+		"-state", filepath.Join(tmp, "state"),
+		"-uidl", filepath.Join(tmp, "uidl"),
+		"-outbox", filepath.Join(tmp, "outbox"),
+		"-retry", "1",
+	}
+	err := f.Parse(append(defaults, args...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c.Execute(context.Background(), f)
+}
+
+func TestOutboxFlushBeforePing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	dir, dst := t.TempDir(), t.TempDir()
+	o, err := outbox.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = o.Put(outbox.Entry{Command: "test", Name: "test_1", Desc: "shop 1", Dst: "dir://" + dst, API: 2}, strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ping of -srv fails, the kept payload is delivered anyway
+	if s := execute(t, NewCmdTst(), "-srv", srv.URL, "-outbox", dir); s != subcommands.ExitFailure {
+		t.Errorf("status %v", s)
+	}
+
+	if l, _ := o.List(""); len(l) != 0 {
+		t.Errorf("outbox %v", l)
+	}
+	if l, _ := ioutil.ReadDir(dst); len(l) != 1 {
+		t.Errorf("delivered %d files", len(l))
+	}
+}
+
+func TestOutboxDefault(t *testing.T) {
+	f := flag.NewFlagSet("test", flag.ContinueOnError)
+	NewCmdTst().SetFlags(f)
+
+	if v := f.Lookup("outbox").DefValue; !strings.HasSuffix(v, "-outbox") {
+		t.Errorf("default outbox %s", v)
+	}
+}
@@ -0,0 +1,117 @@
+package run
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxAttachment limits size of rejects file attached to mail
+const maxAttachment = 10 << 20
+
+// rejects is a quarantine csv file of rejected rows:
+// file, line (record number for dbf), reason, reference, original values...
+type rejects struct {
+	sync.Mutex
+	path string
+	f    *os.File
+	w    *csv.Writer
+	err  error // the first write error, later rows are not written
+	done bool
+}
+
+func newRejects(dir, cmd string, t time.Time) *rejects {
+	return &rejects{
+		path: filepath.Join(dir, fmt.Sprintf("%s_%s_rejects.csv", fileName(cmd), t.Format("20060102T150405"))),
+	}
+}
+
+// add writes row, the file is created on the first row
+func (r *rejects) add(file string, line int, e *rowError, values []string) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.err != nil || r.done {
+		return
+	}
+
+	if r.f == nil {
+		r.err = r.create()
+		if r.err != nil {
+			return
+		}
+	}
+
+	rec := make([]string, 0, 4+len(values))
+	rec = append(rec, file, fmt.Sprint(line), e.reason, e.ref)
+	rec = append(rec, values...)
+	r.err = r.w.Write(rec)
+}
+
+func (r *rejects) create() error {
+	err := os.MkdirAll(filepath.Dir(r.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	r.f, err = os.Create(r.path)
+	if err != nil {
+		return err
+	}
+
+	r.w = csv.NewWriter(r.f)
+	return r.w.Write([]string{"file", "line", "reason", "ref", "values"})
+}
+
+// close flushes and closes file, it returns the first error
+func (r *rejects) close() error {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.f == nil || r.done {
+		r.done = true
+		return r.err
+	}
+	r.done = true
+
+	r.w.Flush()
+	if r.err == nil {
+		r.err = r.w.Error()
+	}
+	err := r.f.Close()
+	if r.err == nil {
+		r.err = err
+	}
+
+	return r.err
+}
+
+// written reports whether the file exists
+func (r *rejects) written() bool {
+	r.Lock()
+	defer r.Unlock()
+	return r.f != nil
+}
+
+// attachment opens closed file for mail unless it is too large
+func (r *rejects) attachment() (string, io.ReadCloser) {
+	if r.close() != nil || !r.written() {
+		return "", nil
+	}
+
+	fi, err := os.Stat(r.path)
+	if err != nil || fi.Size() > maxAttachment {
+		return "", nil
+	}
+
+	f, err := os.Open(r.path)
+	if err != nil {
+		return "", nil
+	}
+
+	return filepath.Base(r.path), f
+}
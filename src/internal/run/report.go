@@ -0,0 +1,255 @@
+package run
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"internal/i18n"
+)
+
+// Reasons of rejected rows
+const (
+	reasonDecode = "decode error"
+	reasonShort  = "short row"
+	reasonNumber = "bad number"
+)
+
+// Kinds of unknown references
+const (
+	refShop  = "shop"
+	refDrug  = "drug"
+	refOffer = "offer"
+)
+
+// rowError rejects a row, the run goes on
+type rowError struct {
+	reason string
+	kind   string // kind of unknown reference
+	ref    string
+}
+
+func (e *rowError) Error() string {
+	if e.ref != "" {
+		return fmt.Sprintf("%s %s", e.reason, e.ref)
+	}
+	return e.reason
+}
+
+func rejectRow(reason string) *rowError {
+	return &rowError{reason: reason}
+}
+
+func unknownRef(kind, ref string) *rowError {
+	return &rowError{reason: "unknown " + kind, kind: kind, ref: ref}
+}
+
+// Data structs
+
+type fileReport struct {
+	Name     string         `json:"name"`
+	Read     int            `json:"read"`
+	Accepted int            `json:"accepted"`
+	Rejected int            `json:"rejected"`
+	Reasons  map[string]int `json:"reasons,omitempty"`
+}
+
+type failedPull struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+// report is a data-quality report of a run
+type report struct {
+	Command string                    `json:"command"`
+	Time    time.Time                 `json:"time"`
+	Files   []*fileReport             `json:"files"`
+	Unknown map[string]map[string]int `json:"unknown,omitempty"` // kind -> ID -> rows
+	Failed  []failedPull              `json:"failed,omitempty"`
+	Error   string                    `json:"error,omitempty"`
+	RunID   string                    `json:"run,omitempty"`
+
+	p *i18n.Printer // language of String
+}
+
+func newReport(cmd string, t time.Time, p *i18n.Printer) *report {
+	return &report{
+		Command: cmd,
+		Time:    t,
+		p:       p,
+		Files:   []*fileReport{},
+		Unknown: make(map[string]map[string]int),
+	}
+}
+
+func (r *report) file(name string) *fileReport {
+	for i := range r.Files {
+		if r.Files[i].Name == name {
+			return r.Files[i]
+		}
+	}
+	f := &fileReport{Name: name, Reasons: make(map[string]int)}
+	r.Files = append(r.Files, f)
+	return f
+}
+
+func (r *report) accept(name string) {
+	f := r.file(name)
+	f.Read++
+	f.Accepted++
+}
+
+func (r *report) reject(name string, err *rowError) {
+	f := r.file(name)
+	f.Read++
+	f.Rejected++
+	f.Reasons[err.reason]++
+
+	if err.kind != "" {
+		if r.Unknown[err.kind] == nil {
+			r.Unknown[err.kind] = make(map[string]int)
+		}
+		r.Unknown[err.kind][err.ref]++
+	}
+}
+
+func (r *report) failed(url string, err error) {
+	r.Failed = append(r.Failed, failedPull{URL: url, Error: err.Error()})
+}
+
+func (r *report) empty() bool {
+	return len(r.Files) == 0 && len(r.Failed) == 0
+}
+
+// String returns summary like "apt.csv: read 10, accepted 9, rejected 1 (short row 1); unknown drug 1"
+func (r *report) String() string {
+	var l []string
+	for _, f := range r.Files {
+		s := r.p.Sprintf("%s: read %d, accepted %d, rejected %d", f.Name, f.Read, f.Accepted, f.Rejected)
+		if len(f.Reasons) > 0 {
+			s += " (" + joinCounts(r.p, f.Reasons) + ")"
+		}
+		l = append(l, s)
+	}
+
+	kinds := make([]string, 0, len(r.Unknown))
+	for k := range r.Unknown {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	for _, k := range kinds {
+		ids := make([]string, 0, len(r.Unknown[k]))
+		for id := range r.Unknown[k] {
+			ids = append(ids, id)
+		}
+		l = append(l, r.p.Sprintf("%s %d: %s", r.p.Sprintf("unknown "+k), len(ids), joinNames(r.p, ids)))
+	}
+
+	if len(r.Failed) > 0 {
+		s := make([]string, len(r.Failed))
+		for i := range r.Failed {
+			s[i] = r.Failed[i].URL
+		}
+		l = append(l, r.p.Sprintf("failed downloads %d: %s", len(s), joinNames(r.p, s)))
+	}
+
+	return strings.Join(l, "; ")
+}
+
+// joinCounts joins counts of reasons, reasons are translated
+func joinCounts(p *i18n.Printer, m map[string]int) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	l := make([]string, len(keys))
+	for i, k := range keys {
+		l[i] = p.Sprintf("%s %d", p.Sprintf(k), m[k])
+	}
+	return strings.Join(l, ", ")
+}
+
+// writeFile writes report to dir and returns its path
+func (r *report) writeFile(dir string) (string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return "", err
+	}
+
+	f := filepath.Join(dir, fmt.Sprintf("%s_%s_report.json", fileName(r.Command), r.Time.Format("20060102T150405")))
+	return f, ioutil.WriteFile(f, b, 0644)
+}
+
+// fileName returns name of run (e.g. generic/ave) usable in file names
+func fileName(job string) string {
+	return strings.Replace(job, "/", "_", -1)
+}
+
+// reject counts rejected row in report and writes it to rejects file,
+// line is line of csv record or number of dbf record
+func (c *cmdBase) reject(file string, line int, e *rowError, values []string) {
+	c.report.reject(file, e)
+	c.rejects.add(file, line, e, values)
+}
+
+// parseRecords passes csv records of file to parse, rejected rows are counted
+// in report, it fails if parse fails, if file can not be read or if no row
+// has expected format, vCh is drained anyway
+func (c *cmdBase) parseRecords(file string, vCh <-chan struct {
+	Record []string
+	Line   int
+	Error  error
+}, parse func([]string) error) error {
+	defer func() {
+		for range vCh {
+		}
+	}()
+
+	for v := range vCh {
+		// parse checks number of fields itself
+		err := v.Error
+		var e *csv.ParseError
+		switch {
+		case err == nil || errors.Is(err, csv.ErrFieldCount):
+			err = parse(v.Record)
+		case errors.As(err, &e):
+			err = rejectRow(reasonDecode)
+		default:
+			return fmt.Errorf("%s: %v", file, err)
+		}
+
+		if e, ok := err.(*rowError); ok {
+			c.reject(file, v.Line, e, v.Record)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		c.report.accept(file)
+	}
+
+	f := c.report.file(file)
+	c.log.Info("transform", "stage", stageTransform, "file", file, "read", f.Read, "accepted", f.Accepted, "rejected", f.Rejected)
+
+	// no valid row at all most likely means that format of file is changed
+	bad := f.Reasons[reasonDecode] + f.Reasons[reasonShort]
+	if f.Accepted == 0 && bad > 0 && bad == f.Rejected {
+		return fmt.Errorf("%s: all %d rows rejected (%s)", file, f.Rejected, joinCounts(nil, f.Reasons))
+	}
+
+	return nil
+}
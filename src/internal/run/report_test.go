@@ -0,0 +1,63 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"internal/encoding/csvutil"
+)
+
+func newTestBase(t *testing.T) *cmdBase {
+	c := &cmdBase{name: "test", runName: "test", log: logger}
+	c.report = newReport(c.runName, time.Now(), printerFrom(context.Background()))
+	c.rejects = newRejects(t.TempDir(), c.runName, time.Now())
+	t.Cleanup(func() { _ = c.rejects.close() })
+	return c
+}
+
+func TestParseRecordsStop(t *testing.T) {
+	c := newTestBase(t)
+	vCh := csvutil.NewRecordChan(strings.NewReader(strings.Repeat("1;2\n", 100)), ';', false, 0)
+
+	stop := errors.New("stop")
+	err := c.parseRecords("a.csv", vCh, func([]string) error { return stop })
+	if err != stop {
+		t.Fatalf("error %v", err)
+	}
+
+	// the pipe is drained, so reading goroutine is done
+	if _, ok := <-vCh; ok {
+		t.Error("pipe is not drained")
+	}
+}
+
+func TestParseRecordsReadError(t *testing.T) {
+	c := newTestBase(t)
+	r := io.MultiReader(strings.NewReader("1;2\n\"x\"y;2\n"), &errReader{errors.New("disk failed")})
+
+	var n int
+	err := c.parseRecords("a.csv", csvutil.NewRecordChan(r, ';', false, 0), func([]string) error {
+		n++
+		return nil
+	})
+	if err == nil || err.Error() != "a.csv: disk failed" {
+		t.Fatalf("error %v", err)
+	}
+
+	// bad quote is rejected row, read error fails
+	if f := c.report.file("a.csv"); n != 1 || f.Rejected != 1 || f.Reasons[reasonDecode] != 1 {
+		t.Errorf("parsed %d, report %+v", n, f)
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
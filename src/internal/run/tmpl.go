@@ -0,0 +1,324 @@
+package run
+
+import (
+	"bytes"
+	"flag"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"internal/net/notify"
+	"internal/store/runstate"
+	"internal/version"
+)
+
+// Kinds of notices
+const (
+	noticeError    = "error"
+	noticeRecovery = "recovery"
+	noticeDigest   = "digest"
+)
+
+// notice is data of notification templates, see -tmpl and -tmpl-html
+type notice struct {
+	Kind    string // error, recovery or digest
+	Lang    string // language of notice, see -lang
+	Command string
+	Subject string
+	Text    string // summary of recovery and digest
+	Error   string
+	Time    time.Time
+	Version string
+	Report  *report         // nil if nothing was parsed
+	Sent    string          // delivered shops, empty if nothing was delivered
+	Rejects string          // path of rejects file, empty if nothing was rejected
+	Input   string          // name of offending input file, empty if there are no inputs
+	State   *runstate.State // nil for tools or without -state
+	Log     []string        // the last lines of log, see -log-tail
+	Flags   []flagValue     // flags set on command line, secrets are redacted
+
+	c     *cmdBase
+	err   error
+	files []notify.Attachment
+}
+
+type flagValue struct {
+	Name  string
+	Value string
+}
+
+// defaultTmpl is used without -tmpl or when -tmpl fails
+const defaultTmpl = `{{.DateTime .Time}}: {{.Tr "version %s" .Version}}
+{{- if ne .Kind "error"}}
+
+{{.Text}}
+{{- else}}: {{.Error}}
+{{- if .Report}}
+
+{{.Tr "report"}}: {{.Report}}
+{{- end}}
+{{- with .State}}{{if gt .Failures 1}}
+
+{{$.Tr "failing since %s, %d runs" ($.DateTime .Since) .Failures}}
+{{- if .Suppressed}}, {{$.Tr "%d repeats not notified" .Suppressed}}{{end}}
+{{- end}}{{end}}
+{{- if .Input}}
+
+{{.Tr "input"}}: {{.Input}}
+{{- end}}
+{{- if .Rejects}}
+
+{{.Tr "rejects"}}: {{if .Attach "rejects"}}{{.Tr "attached"}}{{else}}{{.Rejects}}{{end}}
+{{- end}}
+{{- if .Log}}
+
+{{.Tr "log"}}:
+{{- range .Log}}
+{{.}}
+{{- end}}
+{{- end}}
+{{- if .Flags}}
+
+{{.Tr "flags"}}:{{range .Flags}} -{{.Name}}={{.Value}}{{end}}
+{{- end}}
+{{- end}}
+`
+
+var noticeTmpl = template.Must(template.New("notice").Parse(defaultTmpl))
+
+// openTemplates parses -tmpl and -tmpl-html
+func (c *cmdBase) openTemplates() error {
+	if c.flagTmpl != "" {
+		b, err := ioutil.ReadFile(c.flagTmpl)
+		if err != nil {
+			return err
+		}
+		c.tmpl, err = template.New("tmpl").Parse(string(b))
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.flagTmplH != "" {
+		b, err := ioutil.ReadFile(c.flagTmplH)
+		if err != nil {
+			return err
+		}
+		c.tmplHTML, err = htmltemplate.New("tmpl-html").Parse(string(b))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newNotice returns notice of kind with data of run, err is nil unless
+// the run failed
+func (c *cmdBase) newNotice(kind, subj string, err error, st *runstate.State) *notice {
+	n := &notice{
+		Kind:    kind,
+		Lang:    c.p.Lang(),
+		Command: c.runName,
+		Subject: subj,
+		Time:    time.Now(),
+		Version: version.WithBuildInfo(),
+		State:   st,
+		Log:     c.logTail.Lines(),
+		Flags:   c.flags,
+		c:       c,
+		err:     err,
+	}
+
+	if err != nil {
+		n.Error = err.Error()
+		if f := c.inputs.offending(err); f != nil {
+			n.Input = f.Name()
+		}
+	}
+	if c.report != nil && !c.report.empty() {
+		n.Report = c.report
+	}
+	if !c.sent.empty() {
+		n.Sent = c.sent.String()
+	}
+	if c.rejects != nil && c.rejects.written() {
+		n.Rejects = c.rejects.path
+	}
+
+	return n
+}
+
+// Tr translates message of catalog, see i18n.Printer
+func (n *notice) Tr(key string, a ...interface{}) string {
+	return n.c.p.Sprintf(key, a...)
+}
+
+// Date formats date for language of notice
+func (n *notice) Date(t time.Time) string {
+	return n.c.p.Date(t)
+}
+
+// DateTime formats date and time for language of notice
+func (n *notice) DateTime(t time.Time) string {
+	return n.c.p.DateTime(t)
+}
+
+// Attach attaches rejects file ("rejects"), offending input file ("input")
+// or input file by name, it returns name of attachment or empty string if
+// the file is not found, too large or freed (see keptInputs)
+func (n *notice) Attach(what string) string {
+	var a notify.Attachment
+	switch what {
+	case "rejects":
+		name, file := n.c.rejects.attachment()
+		if file == nil {
+			return ""
+		}
+		b, err := ioutil.ReadAll(file)
+		_ = file.Close()
+		if err != nil {
+			return ""
+		}
+		a = notify.Attachment{Name: name, Data: b}
+	default:
+		var f *inputFile
+		if what == "input" {
+			f = n.c.inputs.offending(n.err)
+		} else {
+			f = n.c.inputs.find(what)
+		}
+		if f == nil {
+			return ""
+		}
+		b := f.data()
+		if b == nil {
+			return ""
+		}
+		a = notify.Attachment{Name: f.Name(), Data: b}
+	}
+
+	// text and html templates may attach the same file
+	for i := range n.files {
+		if n.files[i].Name == a.Name {
+			return a.Name
+		}
+	}
+	n.files = append(n.files, a)
+
+	return a.Name
+}
+
+// message renders notice by templates, a failed template is logged and
+// the default one is used instead (no html)
+func (n *notice) message() notify.Message {
+	m := notify.Message{
+		Command: n.Command,
+		Subject: n.Subject,
+		Error:   n.Error,
+		Time:    n.Time,
+	}
+
+	b := new(bytes.Buffer)
+	if n.c.tmpl != nil {
+		err := n.c.tmpl.Execute(b, n)
+		if err != nil {
+			n.c.log.Warn("tmpl failed", "file", n.c.flagTmpl, "err", err)
+			b.Reset()
+		}
+	}
+	if b.Len() == 0 {
+		err := noticeTmpl.Execute(b, n)
+		if err != nil {
+			n.c.log.Error("tmpl failed", "err", err)
+			b.WriteString(n.Error + n.Text)
+		}
+	}
+	m.Text = b.String()
+
+	if n.c.tmplHTML != nil {
+		b = new(bytes.Buffer)
+		err := n.c.tmplHTML.Execute(b, n)
+		if err != nil {
+			n.c.log.Warn("tmpl-html failed", "file", n.c.flagTmplH, "err", err)
+		} else {
+			m.HTML = b.String()
+		}
+	}
+
+	m.Attachments = n.files
+	return m
+}
+
+// secretParams are query parameters of addresses which are redacted
+var secretParams = []string{"key", "pass", "password", "passphrase", "secret", "token", "apikey", "api_key"}
+
+// visitFlags returns flags set on command line, -key is redacted as well as
+// passwords and secret parameters of addresses
+func visitFlags(f *flag.FlagSet) []flagValue {
+	var l []flagValue
+	f.Visit(func(v *flag.Flag) {
+		s := v.Value.String()
+		if v.Name == "key" {
+			s = "xxxxx"
+		} else {
+			s = redactAddrs(s)
+		}
+		l = append(l, flagValue{Name: v.Name, Value: s})
+	})
+	return l
+}
+
+// redactAddrs redacts every address of comma separated list, a value which
+// is not an address but may hide one (e.g. password with '%' or '#') is
+// replaced as a whole
+func redactAddrs(s string) string {
+	l := strings.Split(s, ",")
+	for i := range l {
+		u, err := url.Parse(l[i])
+		if err != nil {
+			if strings.ContainsAny(l[i], "@:") {
+				l[i] = "xxxxx"
+			}
+			continue
+		}
+		if u.Scheme == "" {
+			continue
+		}
+		// e.g. u:p@host or password with '/' is not parsed as user info
+		if strings.Contains(u.Opaque+u.Path+u.Fragment, "@") {
+			l[i] = "xxxxx"
+			continue
+		}
+
+		if u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), "xxxxx")
+			}
+		}
+
+		// path of webhook is often a secret, see notify
+		if strings.HasPrefix(strings.ToLower(u.Scheme), "hook+") && strings.Trim(u.Path, "/") != "" {
+			u.Path, u.RawPath = "/xxxxx", ""
+		}
+
+		q, found := u.Query(), false
+		for k := range q {
+			for _, p := range secretParams {
+				if strings.EqualFold(k, p) {
+					q.Set(k, "xxxxx")
+					found = true
+				}
+			}
+		}
+		if found {
+			u.RawQuery = q.Encode()
+		}
+
+		l[i] = u.String()
+	}
+	return strings.Join(l, ",")
+}
@@ -0,0 +1,216 @@
+package run
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"internal/model"
+	"internal/net/sink"
+)
+
+const maxReportErrors = 10
+
+// pushFunc uploads payload by key, n is ordinal number of payload for logs
+type pushFunc func(ctx context.Context, key string, n int) error
+
+// uploadAll calls push for every key in at most -jobs goroutines and
+// not more often than -rps times per second, it does not stop on error
+// and returns errors of all pushes
+func (c *cmdBase) uploadAll(ctx context.Context, keys []string, push pushFunc) error {
+	c.expect(keys...)
+
+	jobs := c.flagJobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs > len(keys) {
+		jobs = len(keys)
+	}
+
+	var tick <-chan time.Time
+	if c.flagRPS > 0 {
+		t := time.NewTicker(time.Duration(float64(time.Second) / c.flagRPS))
+		defer t.Stop()
+		tick = t.C
+	}
+
+	type job struct {
+		key string
+		n   int
+	}
+
+	pipe := make(chan job)
+	errs := &errList{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range pipe {
+				err := push(ctx, v.key, v.n)
+				if err != nil && err != ctx.Err() {
+					errs.add(err)
+				}
+			}
+		}()
+	}
+
+	// stop feeding workers on cancel, pushes in progress are finished
+loop:
+	for i := range keys {
+		// select picks any ready case, so done ctx is checked first
+		if ctx.Err() != nil {
+			break
+		}
+		if tick != nil {
+			select {
+			case <-ctx.Done():
+				break loop
+			case <-tick:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			break loop
+		case pipe <- job{keys[i], i + 1}:
+		}
+	}
+	close(pipe)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		errs.add(err)
+	}
+
+	return errs.err(len(keys))
+}
+
+// gzipJSON returns gzip(json) of v
+func gzipJSON(v interface{}) (*bytes.Buffer, error) {
+	b := new(bytes.Buffer)
+	w := gzip.NewWriter(b)
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// pushStock sends stock list of shop in wire format of command, with -delta
+// unchanged shops are skipped and changed ones may be sent as priceDiff
+func (c *cmdBase) pushStock(ctx context.Context, l *model.StockList, key string, n int) error {
+	desc := fmt.Sprintf("%s (%d) %s %d", c.runName, n, key, len(l.Items))
+	c.sent.count(c.payloadName(key), len(l.Items))
+
+	last, err := c.lastStock(key)
+	if err != nil {
+		return err
+	}
+	if last != nil && last.Hash == l.Hash() {
+		c.sent.skip(c.payloadName(key))
+		c.log.Info("skip unchanged", "stage", stagePush, "payload", desc, "shop", key)
+		return nil
+	}
+
+	var v interface{}
+	api := c.wire.API()
+	if last != nil && c.flagDelta == deltaDiff {
+		d := model.Compare(last.Stock, l)
+		v, api = d.Wire(), sink.V2
+		desc = fmt.Sprintf("%s (%d) %s diff %d", c.runName, n, key, d.Len())
+	} else {
+		v, err = c.wire.EncodeStock(l)
+		if err != nil {
+			return err
+		}
+	}
+
+	b, err := gzipJSON(v)
+	if err != nil {
+		return err
+	}
+
+	err = c.pushGzip(ctx, b, key, desc, apiV(api))
+	if err != nil {
+		return err
+	}
+
+	return c.saveStock(key, l)
+}
+
+// pushSales sends sales report of shop in wire format of command
+func (c *cmdBase) pushSales(ctx context.Context, s *model.Sales, key string, n int) error {
+	v, err := c.wire.EncodeSales(s)
+	if err != nil {
+		return err
+	}
+
+	b, err := gzipJSON(v)
+	if err != nil {
+		return err
+	}
+
+	c.sent.count(c.payloadName(key), len(s.Items))
+	return c.pushGzip(ctx, b, key, fmt.Sprintf("%s (%d) %s %d", c.runName, n, s.Source, len(s.Items)), apiV(c.wire.API()))
+}
+
+// errList collects errors of concurrent pushes
+type errList struct {
+	sync.Mutex
+	l []error
+}
+
+func (e *errList) add(err error) {
+	if err == nil {
+		return
+	}
+	e.Lock()
+	defer e.Unlock()
+	e.l = append(e.l, err)
+}
+
+// err returns nil, the only error or error like "2 of 10 uploads failed: a; b",
+// the latter is deliveryError if every error is
+func (e *errList) err(total int) error {
+	e.Lock()
+	defer e.Unlock()
+
+	switch len(e.l) {
+	case 0:
+		return nil
+	case 1:
+		return e.l[0]
+	}
+
+	all := true // failed deliveries
+	for i := range e.l {
+		all = all && isDelivery(e.l[i])
+	}
+
+	s := make([]string, 0, maxReportErrors)
+	for i := 0; i < len(e.l) && i < maxReportErrors; i++ {
+		s = append(s, e.l[i].Error())
+	}
+	if len(e.l) > maxReportErrors {
+		s = append(s, fmt.Sprintf("and %d more", len(e.l)-maxReportErrors))
+	}
+
+	err := fmt.Errorf("%d of %d uploads failed: %s", len(e.l), total, strings.Join(s, "; "))
+	if all {
+		return failedDelivery(err)
+	}
+	return err
+}
@@ -0,0 +1,147 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUploadAll(t *testing.T) {
+	keys := make([]string, 25)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i + 1)
+	}
+
+	for _, v := range []struct {
+		jobs int
+		fail func(n int) error
+		msg  string
+		all  bool // every failure is delivery error
+	}{
+		{4, func(n int) error { return nil }, "", false},
+		{4, func(n int) error {
+			if n%2 == 0 {
+				return failedDelivery(fmt.Errorf("503: shop %d", n))
+			}
+			return nil
+		}, "12 of 25 uploads failed: ", true},
+		{1, func(n int) error {
+			if n == 7 {
+				return errors.New("json: shop 7")
+			}
+			return nil
+		}, "json: shop 7", false},
+		{8, func(n int) error {
+			if n == 3 {
+				return errors.New("json: shop 3")
+			}
+			return failedDelivery(fmt.Errorf("503: shop %d", n))
+		}, "25 of 25 uploads failed: ", false},
+	} {
+		c := newTestBase(t)
+		c.flagJobs = v.jobs
+
+		var (
+			mu         sync.Mutex
+			busy, peak int
+			pushed     = make(map[string]bool)
+		)
+		err := c.uploadAll(context.Background(), keys, func(ctx context.Context, key string, n int) error {
+			mu.Lock()
+			busy++
+			if busy > peak {
+				peak = busy
+			}
+			pushed[key] = true
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			busy--
+			mu.Unlock()
+			return v.fail(n)
+		})
+
+		if len(pushed) != len(keys) || peak > v.jobs {
+			t.Errorf("jobs %d: %d pushed, %d at once", v.jobs, len(pushed), peak)
+		}
+		if v.msg == "" && err != nil || v.msg != "" && (err == nil || !strings.HasPrefix(err.Error(), v.msg)) {
+			t.Errorf("jobs %d: error %v, want %s", v.jobs, err, v.msg)
+			continue
+		}
+		if err != nil && isDelivery(err) != v.all {
+			t.Errorf("jobs %d: delivery %v", v.jobs, isDelivery(err))
+		}
+		if n := strings.Count(fmt.Sprint(err), "shop "); err != nil && n > maxReportErrors {
+			t.Errorf("jobs %d: %d errors in message", v.jobs, n)
+		}
+	}
+}
+
+func TestUploadAllCancel(t *testing.T) {
+	c := newTestBase(t)
+	c.flagJobs = 2
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		pushed int
+	)
+	err := c.uploadAll(ctx, []string{"1", "2", "3", "4", "5", "6"}, func(ctx context.Context, key string, n int) error {
+		mu.Lock()
+		pushed++
+		mu.Unlock()
+		switch n {
+		case 1:
+			<-ctx.Done()
+		case 2:
+			cancel()
+		}
+		return ctx.Err()
+	})
+
+	// errors of pushes canceled by ctx are reported once, the feeder may
+	// hand out one job while it sees the cancel
+	if err != context.Canceled {
+		t.Errorf("error %v", err)
+	}
+	if pushed > 3 {
+		t.Errorf("%d pushed after cancel", pushed)
+	}
+}
+
+func TestErrListDelivery(t *testing.T) {
+	push := failedDelivery(errors.New("503 Service Unavailable: shop 1"))
+	data := errors.New("json: unsupported value")
+
+	for _, v := range []struct {
+		errs     []error
+		delivery bool
+	}{
+		{[]error{push}, true},
+		{[]error{data}, false},
+		{[]error{push, push}, true},
+		{[]error{push, data}, false},
+	} {
+		e := &errList{}
+		for _, err := range v.errs {
+			e.add(err)
+		}
+		if err := e.err(10); isDelivery(err) != v.delivery {
+			t.Errorf("%v: delivery %v, want %v", err, isDelivery(err), v.delivery)
+		}
+	}
+
+	// marked error stays marked when wrapped with %w
+	if !isDelivery(fmt.Errorf("stl: %w", push)) || isDelivery(nil) || failedDelivery(nil) != nil {
+		t.Error("delivery is lost")
+	}
+}
@@ -0,0 +1,259 @@
+package ledger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fileName = "ledger.json"
+
+// Entry is a source file processed by command
+type Entry struct {
+	Command string    `json:"command"`
+	Origin  string    `json:"origin"` // source URL without credentials
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	MTime   time.Time `json:"mtime,omitempty"`
+	Hash    string    `json:"hash"`           // sha256 of content
+	Time    time.Time `json:"time"`           // time of processing
+	Seen    time.Time `json:"seen,omitempty"` // the last time file was found at origin
+}
+
+func (e Entry) key() string {
+	return e.Command + "\x00" + e.Origin + "\x00" + e.Name + "\x00" + e.Hash
+}
+
+func (e Entry) seen() time.Time {
+	if e.Seen.After(e.Time) {
+		return e.Seen
+	}
+	return e.Time
+}
+
+// Ledger is a persistent on-disk list of processed files, a file is the same
+// if command, origin, name and content hash are the same, files which are
+// not seen for keep time are forgotten
+type Ledger struct {
+	mu      sync.Mutex
+	dir     string
+	keep    time.Duration
+	entries []Entry
+	index   map[string]int // key of entry to position in entries
+}
+
+// Open opens (creates) ledger in dir, entries which are not seen for keep
+// time are pruned (0 keeps forever)
+func Open(dir string, keep time.Duration) (*Ledger, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("ledger: dir must be defined")
+	}
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Ledger{dir: dir, keep: keep}
+	err = l.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (l *Ledger) load() error {
+	l.entries = nil
+	b, err := ioutil.ReadFile(l.path())
+	if os.IsNotExist(err) {
+		l.reindex()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(b, &l.entries)
+	if err != nil {
+		return fmt.Errorf("ledger: %s: %v", l.path(), err)
+	}
+
+	l.prune(time.Now())
+	return nil
+}
+
+// prune removes entries which are not seen since now-keep
+func (l *Ledger) prune(now time.Time) []Entry {
+	if l.keep <= 0 {
+		l.reindex()
+		return nil
+	}
+	t := now.Add(-l.keep)
+	return l.remove(func(x Entry) bool { return x.seen().Before(t) })
+}
+
+func (l *Ledger) reindex() {
+	l.index = make(map[string]int, len(l.entries))
+	for i := range l.entries {
+		l.index[l.entries[i].key()] = i
+	}
+}
+
+// Dir returns ledger directory
+func (l *Ledger) Dir() string {
+	return l.dir
+}
+
+// Has reports whether file e is processed already
+func (l *Ledger) Has(e Entry) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, ok := l.index[e.key()]
+	return ok
+}
+
+// Add records processed files, an entry of the same file is replaced,
+// ledger is reloaded before, so entries added by other runs are kept
+func (l *Ledger) Add(e ...Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.load()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, v := range e {
+		if v.Time.IsZero() {
+			v.Time = now
+		}
+		if i, ok := l.index[v.key()]; ok {
+			l.entries[i] = v
+			continue
+		}
+		l.index[v.key()] = len(l.entries)
+		l.entries = append(l.entries, v)
+	}
+
+	return l.write()
+}
+
+// Touch records that processed files are still found at origin, so their
+// entries are kept, unknown files are ignored
+func (l *Ledger) Touch(e ...Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.load()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, v := range e {
+		if i, ok := l.index[v.key()]; ok {
+			l.entries[i].Seen = now
+		}
+	}
+
+	return l.write()
+}
+
+// List returns entries of command (all if cmd is empty) sorted by time
+func (l *Ledger) List(cmd string) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	res := make([]Entry, 0, len(l.entries))
+	for _, v := range l.entries {
+		if cmd == "" || v.Command == cmd {
+			res = append(res, v)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Time.Before(res[j].Time) })
+	return res
+}
+
+// Forget removes entries of command (all if cmd is empty) whose name matches
+// pattern (path.Match, case insensitive), so the files are processed again,
+// it returns removed entries
+func (l *Ledger) Forget(cmd, pattern string) ([]Entry, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("ledger: %v", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.load()
+	if err != nil {
+		return nil, err
+	}
+
+	res := l.remove(func(x Entry) bool {
+		ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(x.Name))
+		return ok && (cmd == "" || x.Command == cmd)
+	})
+	if len(res) == 0 {
+		return nil, nil
+	}
+
+	return res, l.write()
+}
+
+func (l *Ledger) remove(fn func(Entry) bool) []Entry {
+	var res []Entry
+	n := 0
+	for _, v := range l.entries {
+		if fn(v) {
+			res = append(res, v)
+			continue
+		}
+		l.entries[n] = v
+		n++
+	}
+	l.entries = l.entries[:n]
+	l.reindex()
+	return res
+}
+
+func (l *Ledger) write() error {
+	b, err := json.MarshalIndent(l.entries, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(l.dir, ".tmp_")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), l.path())
+}
+
+func (l *Ledger) path() string {
+	return filepath.Join(l.dir, fileName)
+}
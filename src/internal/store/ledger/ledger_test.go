@@ -0,0 +1,99 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLedger(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := Entry{Command: "bel", Origin: "ftp://example.com", Name: "a.zip", Hash: "1"}
+	b := Entry{Command: "bel", Origin: "ftp://example.com", Name: "B.zip", Hash: "2"}
+	err = l.Add(a, b, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := a
+	c.Hash = "3" // content of a.zip is changed
+	if !l.Has(a) || !l.Has(b) || l.Has(c) {
+		t.Errorf("has %v", l.List(""))
+	}
+	if n := len(l.List("bel")); n != 2 {
+		t.Errorf("%d entries", n)
+	}
+
+	// entries are kept on disk
+	l, err = Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := l.Forget("", "b.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Name != "B.zip" || l.Has(b) || !l.Has(a) {
+		t.Errorf("forget %v, left %v", res, l.List(""))
+	}
+}
+
+func TestLedgerKeep(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	a := Entry{Command: "bel", Name: "a.zip", Hash: "1", Time: old}
+	b := Entry{Command: "bel", Name: "b.zip", Hash: "2", Time: old}
+	c := Entry{Command: "bel", Name: "c.zip", Hash: "3"}
+	err = l.Add(a, b, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a.zip is still at origin, b.zip is not
+	l, err = Open(dir, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Has(a) || l.Has(b) || !l.Has(c) {
+		t.Errorf("entries %v", l.List(""))
+	}
+
+	l, err = Open(dir, 72*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Touch(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Open(dir, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Has(a) || l.Has(b) || !l.Has(c) {
+		t.Errorf("entries %v", l.List(""))
+	}
+
+	// pruned entries are removed from disk on the next write
+	err = l.Add(Entry{Command: "stl", Name: "d.csv", Hash: "4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err = Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Has(b) || len(l.List("")) != 3 {
+		t.Errorf("entries %v", l.List(""))
+	}
+}
@@ -0,0 +1,169 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	extData = ".gz"
+	extMeta = ".json"
+)
+
+var seq uint64
+
+// Entry is metadata of payload kept in outbox
+type Entry struct {
+	ID      string    `json:"-"`
+	Command string    `json:"command"`
+	Name    string    `json:"name"`
+	Desc    string    `json:"desc"`
+	Dst     string    `json:"dst"`
+	API     int       `json:"api"`
+	Key     string    `json:"key,omitempty"`
+	Tag     string    `json:"tag,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Outbox is a persistent on-disk store of payloads which are not delivered yet
+type Outbox struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// Open opens (creates) outbox in dir
+func Open(dir string) (*Outbox, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("outbox: dir must be defined")
+	}
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Outbox{dir: dir}, nil
+}
+
+// Dir returns outbox directory
+func (o *Outbox) Dir() string {
+	return o.dir
+}
+
+// Put saves payload and its metadata, the payload is safe when Put returns nil
+func (o *Outbox) Put(e Entry, r io.Reader) (Entry, error) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.ID = fmt.Sprintf("%s_%d_%d", e.Name, e.Time.UnixNano(), atomic.AddUint64(&seq, 1))
+
+	err := o.writeFile(e.ID+extData, r)
+	if err != nil {
+		return e, err
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return e, err
+	}
+
+	// meta is written last, so entry without meta is incomplete and ignored
+	err = o.writeFile(e.ID+extMeta, strings.NewReader(string(b)))
+	if err != nil {
+		_ = os.Remove(o.path(e.ID + extData))
+		return e, err
+	}
+
+	return e, nil
+}
+
+func (o *Outbox) writeFile(name string, r io.Reader) error {
+	f, err := ioutil.TempFile(o.dir, ".tmp_")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), o.path(name))
+}
+
+func (o *Outbox) path(name string) string {
+	return filepath.Join(o.dir, name)
+}
+
+// List returns entries of command (all if cmd is empty) sorted by time
+func (o *Outbox) List(cmd string) ([]Entry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	l, err := filepath.Glob(o.path("*" + extMeta))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Entry, 0, len(l))
+	for i := range l {
+		b, err := ioutil.ReadFile(l[i])
+		if err != nil {
+			return nil, err
+		}
+
+		var e Entry
+		err = json.Unmarshal(b, &e)
+		if err != nil {
+			return nil, fmt.Errorf("outbox: %s: %v", l[i], err)
+		}
+		e.ID = strings.TrimSuffix(filepath.Base(l[i]), extMeta)
+
+		if cmd != "" && e.Command != cmd {
+			continue
+		}
+		res = append(res, e)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Time.Before(res[j].Time) })
+	return res, nil
+}
+
+// Open opens payload of entry
+func (o *Outbox) Open(e Entry) (io.ReadCloser, error) {
+	return os.Open(o.path(e.ID + extData))
+}
+
+// Remove removes delivered entry
+func (o *Outbox) Remove(e Entry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	err := os.Remove(o.path(e.ID + extMeta))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = os.Remove(o.path(e.ID + extData))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package runstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// digestFile keeps time of the last digest
+const digestFile = ".digest.json"
+
+// State is the outcome of the last runs of command, it is used to notify
+// about the first failure, recovery and in digests
+type State struct {
+	Command string    `json:"command"`
+	LastRun time.Time `json:"lastRun"`
+	LastOK  time.Time `json:"lastOK,omitempty"`
+	Shops   int       `json:"shops"` // delivered by the last successful run
+	Items   int       `json:"items"`
+
+	Failing     bool      `json:"failing"`
+	Since       time.Time `json:"since,omitempty"` // first failure of the series
+	Failures    int       `json:"failures"`        // runs failed since
+	Error       string    `json:"error,omitempty"` // last error
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Notified    time.Time `json:"notified,omitempty"` // last failure notification
+	Suppressed  int       `json:"suppressed"`         // repeats not notified since
+}
+
+// Store is a persistent on-disk store of states, one file per command
+type Store struct {
+	dir string
+}
+
+// Open opens (creates) store in dir
+func Open(dir string) (*Store, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("runstate: dir must be defined")
+	}
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{dir: dir}, nil
+}
+
+// Dir returns store directory
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Get returns state of cmd, it is empty if command never ran
+func (s *Store) Get(cmd string) (*State, error) {
+	v := &State{Command: cmd}
+	err := s.read(s.path(cmd), v)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Put replaces state of command
+func (s *Store) Put(v *State) error {
+	return s.write(s.path(v.Command), v)
+}
+
+// List returns states of all commands sorted by name
+func (s *Store) List() ([]*State, error) {
+	l, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*State, 0, len(l))
+	for i := range l {
+		if strings.HasPrefix(filepath.Base(l[i]), ".") {
+			continue // digest
+		}
+		v := &State{}
+		err = s.read(l[i], v)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Command < res[j].Command })
+	return res, nil
+}
+
+// Digested returns time of the last digest, zero if there was none
+func (s *Store) Digested() (time.Time, error) {
+	var v struct {
+		Time time.Time `json:"time"`
+	}
+	err := s.read(filepath.Join(s.dir, digestFile), &v)
+	return v.Time, err
+}
+
+// SetDigested records time of digest
+func (s *Store) SetDigested(t time.Time) error {
+	return s.write(filepath.Join(s.dir, digestFile), struct {
+		Time time.Time `json:"time"`
+	}{t})
+}
+
+// read reads JSON file to v, a missing file leaves v as is
+func (s *Store) read(name string, v interface{}) error {
+	b, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return fmt.Errorf("runstate: %s: %v", name, err)
+	}
+
+	return nil
+}
+
+func (s *Store) write(name string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(s.dir, ".tmp_")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+func (s *Store) path(cmd string) string {
+	r := strings.NewReplacer("/", "_", "\\", "_", "..", "_")
+	return filepath.Join(s.dir, r.Replace(cmd)+".json")
+}
@@ -0,0 +1,103 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"internal/model"
+)
+
+// Snapshot is stock list of shop which was delivered last time
+type Snapshot struct {
+	Hash  string           `json:"hash"`
+	Time  time.Time        `json:"time"`
+	Stock *model.StockList `json:"stock"`
+}
+
+// Store is a persistent on-disk store of snapshots by command and key
+// (e.g. shop ID), one file per snapshot
+type Store struct {
+	dir string
+}
+
+// Open opens (creates) store in dir
+func Open(dir string) (*Store, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("snapshot: dir must be defined")
+	}
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{dir: dir}, nil
+}
+
+// Dir returns store directory
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Get returns snapshot of cmd by key, it returns nil if there is no snapshot
+func (s *Store) Get(cmd, key string) (*Snapshot, error) {
+	b, err := ioutil.ReadFile(s.path(cmd, key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Snapshot{}
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: %s %s: %v", cmd, key, err)
+	}
+
+	return v, nil
+}
+
+// Put replaces snapshot of cmd by key with l
+func (s *Store) Put(cmd, key string, l *model.StockList) error {
+	b, err := json.Marshal(Snapshot{Hash: l.Hash(), Time: time.Now(), Stock: l})
+	if err != nil {
+		return err
+	}
+
+	name := s.path(cmd, key)
+	err = os.MkdirAll(filepath.Dir(name), 0700)
+	if err != nil {
+		return err
+	}
+
+	// the old snapshot is kept until the new one is written completely
+	f, err := ioutil.TempFile(filepath.Dir(name), ".tmp_")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+func (s *Store) path(cmd, key string) string {
+	r := strings.NewReplacer("/", "_", "\\", "_", "..", "_")
+	return filepath.Join(s.dir, r.Replace(cmd), r.Replace(key)+".json")
+}
@@ -0,0 +1,133 @@
+package spool
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// DefaultLimit is max size of spooled file if nothing is defined
+const DefaultLimit = 1 << 30
+
+// Spool keeps downloaded streams in temporary files instead of memory
+type Spool struct {
+	Dir   string // directory for temporary files, os.TempDir() if empty
+	Limit int64  // max size of file, DefaultLimit if 0
+}
+
+// Default is spool in os.TempDir() with DefaultLimit
+var Default = Spool{}
+
+// Reader is content of spooled file
+type Reader interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+	Size() int64
+}
+
+// File is a temporary file, it is removed when closed (on unix right away,
+// the data is available until the file is closed or garbage collected)
+type File struct {
+	*os.File
+	size int64
+	temp string // not empty if temporary file is not removed yet
+}
+
+// Size returns size of file
+func (f *File) Size() int64 {
+	return f.size
+}
+
+// Close closes and removes file
+func (f *File) Close() error {
+	err := f.File.Close()
+	if f.temp != "" {
+		_ = os.Remove(f.temp)
+		f.temp = ""
+	}
+	return err
+}
+
+// Open opens existing file for reading without copying, it is not removed
+// when closed
+func (s Spool) Open(name string) (*File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err == nil && fi.Size() > s.limit() {
+		err = &LimitError{Limit: s.limit()}
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return &File{File: f, size: fi.Size()}, nil
+}
+
+// LimitError is returned if stream is larger than Limit
+type LimitError struct {
+	Limit int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("spool: file is larger than %d bytes", e.Limit)
+}
+
+func (s Spool) limit() int64 {
+	if s.Limit <= 0 {
+		return DefaultLimit
+	}
+	return s.Limit
+}
+
+// Copy copies r to temporary file and returns it ready for reading
+func (s Spool) Copy(r io.Reader) (*File, error) {
+	if s.Dir != "" {
+		err := os.MkdirAll(s.Dir, 0700)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	t, err := ioutil.TempFile(s.Dir, "spool-")
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{File: t, temp: t.Name()}
+	if os.Remove(f.temp) == nil {
+		f.temp = ""
+	}
+
+	n := s.limit()
+	f.size, err = io.Copy(t, io.LimitReader(r, n+1))
+	if err == nil && f.size > n {
+		err = &LimitError{Limit: n}
+	}
+	if err != nil {
+		goto fail
+	}
+
+	_, err = t.Seek(0, io.SeekStart)
+	if err != nil {
+		goto fail
+	}
+
+	return f, nil
+fail:
+	_ = f.Close()
+	return nil, err
+}
+
+// Sizer is a file with known size which supports random access (e.g. zip)
+type Sizer interface {
+	io.ReaderAt
+	Size() int64
+}
@@ -1,31 +1,68 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
 	"internal/cli"
+	"internal/i18n"
+	"internal/logs"
+	"internal/run"
+	"internal/version"
 )
 
 var (
 	flagVerbose  = flag.Bool("verbose", false, "make logging visible")
 	flagHideTime = flag.Bool("hidetime", false, "show time when verbose")
+	flagDryRun   = flag.Bool("dry-run", false, "run without ping, deletes and pushes, write payloads to -dry-dir")
+	flagDryDir   = flag.String("dry-dir", filepath.Join(os.TempDir(), version.AppName()+"-dry-run"), "directory for dry-run payloads and summary")
+	flagLogFmt   = flag.String("log-format", "text", "format of logs text or json")
+	flagLogLevel = flag.String("log-level", "info", "level of logs debug, info, warn or error")
+	flagLogPkgs  = flag.String("log-levels", "", "levels of packages, e.g. ftpcli=debug,mailcli=debug,httpcli=warn")
+	flagLang     = flag.String("lang", "", "language of notifications, reports and output en|uk|ru (default LANG or en)")
 )
 
 func main() {
 	flag.Parse()
-	initLogger(*flagVerbose, *flagHideTime)
-	os.Exit(cli.Run())
+	err := initLogger(*flagVerbose, *flagHideTime)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	lang, err := i18n.Parse(*flagLang)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// SIGINT/SIGTERM (e.g. systemctl stop) cancels the run between stages and shops
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	ctx = run.WithLang(ctx, lang)
+	if *flagDryRun {
+		ctx = run.WithDryRun(ctx, *flagDryDir)
+	}
+
+	code := cli.Run(ctx)
+	stop()
+	os.Exit(code)
 }
 
-func initLogger(v, ht bool) {
-	log.SetOutput(ioutil.Discard)
+func initLogger(v, ht bool) error {
+	w := ioutil.Discard
 	if v {
-		log.SetOutput(os.Stderr)
-	}
-	if ht {
-		log.SetFlags(0)
+		w = os.Stderr
 	}
+	return logs.Init(w, logs.Options{
+		Format: *flagLogFmt,
+		Level:  *flagLogLevel,
+		Levels: *flagLogPkgs,
+		NoTime: ht,
+	})
 }